@@ -0,0 +1,299 @@
+package jsparser
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// ArrayParser parses the elements of one large top level array concurrently.
+// The input is split into byte ranges which are scanned in parallel for
+// element boundaries, and the elements between boundaries are parsed on
+// separate goroutines.
+//
+// A range may begin inside a string, so its scan can not know which quotes
+// open strings. Each range is therefore scanned speculatively once for every
+// state it may begin in (outside a string, inside a string, after a backslash
+// in a string). Walking the ranges in order then picks the right speculation
+// of each range from the state the previous one ended in.
+type ArrayParser struct {
+	chunkedParser[*ArrayParser]
+}
+
+// speculation is the scan of a range from an assumed state
+type speculation struct {
+	inString bool
+	escaped  bool
+	depth    int     // nesting relative to the start of the range
+	commas   []int64 // offset of the first comma at relative depth -i, or -1
+	closes   []int64 // offset where the relative depth first drops to -i-1
+}
+
+// NewArrayParser returns a parser of the first size bytes of reader which
+// uses the given number of goroutines
+func NewArrayParser(reader io.ReaderAt, size int64, workers int) *ArrayParser {
+
+	a := &ArrayParser{}
+	a.setup(a, reader, size, workers, a.split, a.parseChunk)
+	return a
+
+}
+
+// split divides the input at commas separating elements of the array. The
+// first chunk begins at the start of the input and the others at a comma.
+func (a *ArrayParser) split(emit func(c *chunk) bool) {
+
+	open, err := a.arrayStart()
+
+	if err != nil {
+		emit(&chunk{start: 0, end: a.size, err: err})
+		return
+	}
+
+	if open < 0 {
+		// not an array, parse it as a whole to report the error
+		emit(&chunk{start: 0, end: a.size})
+		return
+	}
+
+	var starts []int64
+	for start := open + 1; start < a.size; start += a.chunkSize {
+		starts = append(starts, start)
+	}
+
+	specs := make([][3]*speculation, len(starts))
+	errs := make([]error, len(starts))
+	done := make([]chan struct{}, len(starts))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	// ranges are scanned at most 2 per worker ahead of the walk below
+	ahead := make(chan struct{}, a.workers*2)
+	quit := make(chan struct{})
+	defer close(quit)
+
+	indexes := make(chan int)
+	go func() {
+		defer close(indexes)
+		for i := range starts {
+			select {
+			case ahead <- struct{}{}:
+				indexes <- i
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < a.workers; w++ {
+		go func() {
+			for i := range indexes {
+				end := a.size
+				if i+1 < len(starts) {
+					end = starts[i+1]
+				}
+				specs[i], errs[i] = a.speculate(starts[i], end)
+				close(done[i])
+			}
+		}()
+	}
+
+	// walk the ranges from the known state after the opening bracket as
+	// their scans complete. The elements of the array are at depth 1 and the
+	// array ends at depth 0.
+	start := int64(0)
+	depth := 1
+	state := 0
+	for i := range starts {
+
+		<-done[i]
+		<-ahead
+
+		if errs[i] != nil {
+			emit(&chunk{start: start, end: a.size, err: errs[i]})
+			return
+		}
+
+		s := specs[i][state]
+		specs[i] = [3]*speculation{}
+
+		end := int64(-1)
+		if depth-1 < len(s.closes) {
+			end = s.closes[depth-1]
+		}
+
+		if depth-1 < len(s.commas) {
+			if off := s.commas[depth-1]; off >= 0 && (end < 0 || off < end) {
+				if !emit(&chunk{start: start, end: off}) {
+					return
+				}
+				start = off
+			}
+		}
+
+		if end >= 0 {
+			// the array ends in this range, the rest belongs to the last chunk
+			break
+		}
+
+		depth += s.depth
+		state = 0
+		if s.inString {
+			state = 1
+			if s.escaped {
+				state = 2
+			}
+		}
+
+	}
+
+	emit(&chunk{start: start, end: a.size})
+
+}
+
+// arrayStart returns the offset of the opening bracket or -1 if the input
+// does not begin with an array
+func (a *ArrayParser) arrayStart() (int64, error) {
+
+	buf := make([]byte, 4096)
+
+	for off := int64(0); off < a.size; {
+
+		m, err := a.reader.ReadAt(buf[:min(int64(len(buf)), a.size-off)], off)
+
+		for i, b := range buf[:m] {
+			switch b {
+			case ' ', '\t', '\n', '\r':
+			case '[':
+				return off + int64(i), nil
+			default:
+				return -1, nil
+			}
+		}
+
+		off += int64(m)
+
+		if err == io.EOF || (err == nil && m == 0) {
+			break
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+	}
+
+	return -1, nil
+
+}
+
+// speculate scans the range from start to end assuming it begins outside a
+// string, inside a string and after a backslash in a string
+func (a *ArrayParser) speculate(start int64, end int64) ([3]*speculation, error) {
+
+	specs := [3]*speculation{
+		{},
+		{inString: true},
+		{inString: true, escaped: true},
+	}
+
+	buf := make([]byte, 65536)
+
+	for off := start; off < end; {
+
+		m, err := a.reader.ReadAt(buf[:min(int64(len(buf)), end-off)], off)
+
+		for _, s := range specs {
+			s.scan(buf[:m], off)
+		}
+
+		off += int64(m)
+
+		if err == io.EOF && off < end {
+			return specs, io.ErrUnexpectedEOF
+		}
+
+		if err != nil && err != io.EOF {
+			return specs, err
+		}
+
+		if m == 0 {
+			return specs, io.ErrNoProgress
+		}
+
+	}
+
+	return specs, nil
+
+}
+
+// scan continues the speculation over block, which begins at offset off
+func (s *speculation) scan(block []byte, off int64) {
+
+	for i := 0; i < len(block); i++ {
+
+		if s.inString {
+			if s.escaped {
+				s.escaped = false
+				continue
+			}
+			for ; i < len(block) && !stringStop[block[i]]; i++ {
+			}
+			if i == len(block) {
+				return
+			}
+			switch block[i] {
+			case '\\':
+				s.escaped = true
+			case '"':
+				s.inString = false
+			}
+			continue
+		}
+
+		switch block[i] {
+		case '"':
+			s.inString = true
+		case '[', '{':
+			s.depth++
+		case ']', '}':
+			s.depth--
+			if s.depth < 0 && len(s.closes) == -s.depth-1 {
+				s.closes = append(s.closes, off+int64(i))
+			}
+		case ',':
+			if s.depth <= 0 {
+				for len(s.commas) <= -s.depth {
+					s.commas = append(s.commas, -1)
+				}
+				if s.commas[-s.depth] < 0 {
+					s.commas[-s.depth] = off + int64(i)
+				}
+			}
+		}
+
+	}
+
+}
+
+// parseChunk sends the elements of a chunk. A comma at the start of the
+// chunk is read as an opening bracket and a closing bracket is added to all
+// chunks but the last.
+func (a *ArrayParser) parseChunk(c *chunk) {
+
+	var reader io.Reader = io.NewSectionReader(a.reader, c.start, c.end-c.start)
+
+	if c.start > 0 {
+		reader = io.MultiReader(strings.NewReader("["), io.NewSectionReader(a.reader, c.start+1, c.end-c.start-1))
+	}
+
+	if c.end < a.size {
+		reader = io.MultiReader(reader, strings.NewReader("]"))
+	}
+
+	p := NewJSONParser(bufio.NewReaderSize(reader, 65536), "").SkipProps(a.skipProps)
+
+	a.sendChunk(p, c)
+
+}
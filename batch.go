@@ -0,0 +1,108 @@
+package jsparser
+
+import (
+	"sync"
+	"time"
+)
+
+// batches buffered by the channel of StreamBatches
+const batchChanSize = 16
+
+// batcher groups results into batches for StreamBatches. Batches are sent by
+// the parser and by flush timers, so sending is serialized by mu.
+type batcher struct {
+	mu      sync.Mutex
+	out     chan []*JSON
+	batch   []*JSON
+	bytes   uint64 // input bytes of the results in batch
+	size    int
+	budget  uint64
+	timeout time.Duration
+	gen     uint64 // incremented by every flush, so that stale timers do nothing
+	timer   *time.Timer
+	closed  bool
+}
+
+// StreamBatches works like Stream but sends results in batches. A batch is
+// sent once it holds size results or results read from budget bytes of
+// input, and at the latest timeout after its first result was added, so that
+// slow inputs don't hold results back. Zero disables a bound, and without
+// both size and budget batches hold up to 256 results.
+func (j *JsonParser) StreamBatches(size int, budget int, timeout time.Duration) chan []*JSON {
+
+	if size <= 0 && budget <= 0 {
+		size = 256
+	}
+
+	j.isResArr = false
+	j.forEach = nil
+	j.batches = &batcher{
+		out:     make(chan []*JSON, batchChanSize),
+		size:    size,
+		budget:  uint64(max(budget, 0)),
+		timeout: timeout,
+	}
+
+	go j.parse()
+
+	return j.batches.out
+
+}
+
+// add appends res, read from size bytes of input, to the batch
+func (b *batcher) add(res *JSON, size uint64) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.batch = append(b.batch, res)
+	b.bytes += size
+
+	if (b.size > 0 && len(b.batch) >= b.size) || (b.budget > 0 && b.bytes >= b.budget) {
+		b.flush()
+		return
+	}
+
+	if len(b.batch) == 1 && b.timeout > 0 {
+		gen := b.gen
+		b.timer = time.AfterFunc(b.timeout, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if b.gen == gen && !b.closed {
+				b.flush()
+			}
+		})
+	}
+
+}
+
+// flush sends the batch unless it is empty. b.mu must be held.
+func (b *batcher) flush() {
+
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.gen++
+
+	if len(b.batch) == 0 {
+		return
+	}
+
+	b.out <- b.batch
+	b.batch = nil
+	b.bytes = 0
+
+}
+
+// close sends the last batch and closes the channel
+func (b *batcher) close() {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.flush()
+	b.closed = true
+	close(b.out)
+
+}
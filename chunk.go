@@ -0,0 +1,195 @@
+package jsparser
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// chunkedParser holds the configuration and the methods shared by the
+// parsers which split their input into chunks parsed concurrently. P is the
+// embedding parser, which is returned by the chain methods.
+type chunkedParser[P any] struct {
+	TotalReadSize uint64 // bytes of parsed input, read it with atomic.LoadUint64
+	self          P
+	reader        io.ReaderAt
+	size          int64
+	workers       int
+	chunkSize     int64
+	isUnordered   bool
+	skipProps     []string
+	resChan       chan *JSON
+	split         func(emit func(c *chunk) bool)
+	parseChunk    func(c *chunk)
+}
+
+// chunk is a byte range of the input which is parsed on its own
+type chunk struct {
+	start   int64
+	end     int64
+	err     error // reading the input failed
+	resChan chan *JSON
+}
+
+// parseChunks parses the chunks emitted by split on the given number of
+// goroutines and sends their results to resChan, which is closed at the end.
+// Unless unordered the results of each chunk follow the ones of the previous
+// chunk and like in sequential parsing nothing is sent after an error. emit
+// returns false once an error was sent, and the remaining chunks are not
+// parsed.
+func parseChunks(resChan chan *JSON, workers int, unordered bool, split func(emit func(c *chunk) bool), parse func(c *chunk)) {
+
+	defer close(resChan)
+
+	chunks := make(chan *chunk, workers)
+	ordered := make(chan *chunk, workers)
+	shared := make(chan *JSON, 256) // results of all chunks when unordered
+	var failed atomic.Bool
+
+	go func() {
+
+		defer close(chunks)
+		defer close(ordered)
+
+		split(func(c *chunk) bool {
+			if failed.Load() {
+				return false
+			}
+			c.resChan = shared
+			if !unordered {
+				c.resChan = make(chan *JSON, 256)
+				ordered <- c
+			}
+			chunks <- c
+			return true
+		})
+
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range chunks {
+				switch {
+				case failed.Load():
+				case c.err != nil:
+					c.resChan <- &JSON{Err: c.err, ValueType: Invalid}
+				default:
+					parse(c)
+				}
+				if !unordered {
+					close(c.resChan)
+				}
+			}
+		}()
+	}
+
+	if unordered {
+
+		go func() {
+			wg.Wait()
+			close(shared)
+		}()
+
+		for res := range shared {
+			resChan <- res
+		}
+		return
+
+	}
+
+	for c := range ordered {
+		for res := range c.resChan {
+			if !failed.Load() {
+				resChan <- res
+				failed.Store(res.Err != nil)
+			}
+		}
+	}
+
+}
+
+// setup configures a parser of the first size bytes of reader which uses
+// the given number of goroutines
+func (cp *chunkedParser[P]) setup(self P, reader io.ReaderAt, size int64, workers int, split func(emit func(c *chunk) bool), parseChunk func(c *chunk)) {
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	cp.self = self
+	cp.reader = reader
+	cp.size = size
+	cp.workers = workers
+	cp.chunkSize = 4 << 20
+	cp.resChan = make(chan *JSON, 256)
+	cp.split = split
+	cp.parseChunk = parseChunk
+
+}
+
+func (cp *chunkedParser[P]) SkipProps(skipProps []string) P {
+
+	cp.skipProps = append(cp.skipProps, skipProps...)
+	return cp.self
+
+}
+
+// ChunkSize sets the approximate size of the chunks parsed concurrently
+func (cp *chunkedParser[P]) ChunkSize(chunkSize int64) P {
+
+	if chunkSize > 0 {
+		cp.chunkSize = chunkSize
+	}
+	return cp.self
+
+}
+
+// Unordered lets the parser send results as soon as they are parsed instead of in input order
+func (cp *chunkedParser[P]) Unordered() P {
+
+	cp.isUnordered = true
+	return cp.self
+
+}
+
+func (cp *chunkedParser[P]) Stream() chan *JSON {
+
+	go parseChunks(cp.resChan, cp.workers, cp.isUnordered, cp.split, cp.parseChunk)
+
+	return cp.resChan
+
+}
+
+func (cp *chunkedParser[P]) Parse() []*JSON {
+
+	var res []*JSON
+	for json := range cp.Stream() {
+		res = append(res, json)
+	}
+	return res
+
+}
+
+// sendChunk sends the results of the parser of a chunk and adds its progress
+// to TotalReadSize as the results are read
+func (cp *chunkedParser[P]) sendChunk(p *JsonParser, c *chunk) {
+
+	read := uint64(0)
+	size := uint64(c.end - c.start)
+
+	p.ForEach(func(res *JSON) error {
+		// TotalReadSize of p is set before res is passed on
+		if n := min(p.TotalReadSize, size); n > read {
+			atomic.AddUint64(&cp.TotalReadSize, n-read)
+			read = n
+		}
+		c.resChan <- res
+		return nil
+	})
+
+	atomic.AddUint64(&cp.TotalReadSize, size-read)
+
+}
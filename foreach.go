@@ -0,0 +1,52 @@
+package jsparser
+
+import "errors"
+
+// Stop can be returned by the function passed to ForEach to end parsing
+// without an error
+var Stop = errors.New("Stop")
+
+// ForEach parses the input on the calling goroutine and calls fn with every
+// result, without the channel of Stream or the slice of Parse. Results with
+// Err are passed to fn as Stream would send them. When fn returns an error
+// parsing ends and ForEach returns it, or nil for Stop. With Parallel the
+// input is read and decoded on other goroutines, but fn is still called on
+// the calling goroutine.
+func (j *JsonParser) ForEach(fn func(*JSON) error) error {
+
+	j.isResArr = false
+	j.batches = nil
+	j.forEach = fn
+
+	if j.workers > 0 {
+		j.parseCollecting()
+	} else {
+		j.parse()
+	}
+
+	err := j.abortErr
+	j.forEach = nil
+	j.abortErr = nil
+	j.aborted.Store(false)
+
+	if err == Stop {
+		return nil
+	}
+	return err
+
+}
+
+// visit calls the function of ForEach with res unless parsing was aborted
+func (j *JsonParser) visit(res *JSON) {
+
+	if j.aborted.Load() {
+		res.Release()
+		return
+	}
+
+	if err := j.forEach(res); err != nil {
+		j.abortErr = err
+		j.aborted.Store(true)
+	}
+
+}
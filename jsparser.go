@@ -3,8 +3,13 @@ package jsparser
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"strconv"
+	"sync/atomic"
 	"unicode/utf16"
+	"unsafe"
 )
 
 type JsonParser struct {
@@ -12,20 +17,73 @@ type JsonParser struct {
 	loopProp      []byte
 	resChan       chan *JSON
 	isResArr      bool
+	isTopLevel    bool
+	isSeq         bool
+	isMembers     bool
+	isPositions   bool
+	isRaw         bool
+	isPooled      bool
+	isTape        bool
+	isLazy        bool
+	isMapped      bool           // buf is a file mapping, see NewFileJSONParser
+	mapped        []byte         // unmapped by Close
+	maxDepth      int            // levels of results materialized, 0 for all
+	depth         int            // nesting below the result being parsed
+	maxNesting    int            // see Limits
+	lazyContexts  []*lazyContext // shared by the lazy values at each depth
+	maxValueSize  int
+	limit         uint64 // offset the value being parsed may extend to, 0 for none
+	chanSize      int
+	readSize      int               // of the bufio.Reader created by NewParser
+	tape          *Tape             // reused while building tapes
+	keys          map[string]string // interned property names
+	workers       int
+	isUnordered   bool
+	jobs          chan job
+	decoded       chan job
+	inFlight      chan struct{}
+	collected     chan struct{}
+	seq           uint64
+	tracking      bool
+	path          []pathFrame
+	captureProps  map[string]bool
+	context       map[string]interface{}
+	tokenEnd      uint64
+	root          bool
+	seqBuf        []byte
+	seqText       *JsonParser
 	skipProps     map[string]bool
 	TotalReadSize uint64
-	lastReadSize  int
+	sent          uint64   // TotalReadSize when the last result was sent
+	batches       *batcher // of StreamBatches
+	forEach       func(*JSON) error
+	abortErr      error       // returned by forEach
+	aborted       atomic.Bool // set with abortErr, read while parsing
+	buf           []byte      // window of bytes buffered by reader
+	pos           int         // next byte of buf
+	base          uint64      // offset of buf in the input
 	scratch       *scratch
 }
 
 // JSON parsed result
 type JSON struct {
+	Key        string                 // member name when streaming object members
+	Raw        []byte                 // unparsed value in raw mode, usable as json.RawMessage
+	Context    map[string]interface{} // captured properties of enclosing objects, shared between results
 	StringVal  string
 	BoolVal    bool
 	ArrayVals  []interface{}
 	ObjectVals map[string]interface{}
 	ValueType  ValueType
 	Err        error
+	Path       string // JSON path of the result, e.g. $.books[12]
+	Index      int    // position within the loop array, object or value sequence
+	Start      uint64 // offset of the first byte of the value in the input
+	End        uint64 // offset following the last byte of the value
+	Tape       *Tape  // parsed value in tape mode
+
+	spare *spare       // set for results taken from jsonPool
+	lazy  *lazyContext // set for nested values kept in Raw until accessed
 }
 
 // ValueType of JSON value
@@ -47,13 +105,42 @@ func NewJSONParser(reader *bufio.Reader, loopProp string) *JsonParser {
 	j := &JsonParser{
 		reader:    reader,
 		loopProp:  []byte(loopProp),
-		resChan:   make(chan *JSON, 256),
+		chanSize:  256,
 		skipProps: map[string]bool{},
-		scratch:   &scratch{data: make([]byte, 2048), dataRes: make([]*JSON, 2048)},
+		scratch:   &scratch{data: make([]byte, 2048)},
 	}
 	return j
 }
 
+// NewReaderJSONParser returns a parser reading from any reader through a
+// bufio.Reader of the given size, or of the default size when size <= 0
+func NewReaderJSONParser(reader io.Reader, loopProp string, size int) *JsonParser {
+
+	if size <= 0 {
+		return NewJSONParser(bufio.NewReader(reader), loopProp)
+	}
+	return NewJSONParser(bufio.NewReaderSize(reader, size), loopProp)
+
+}
+
+// NewBytesJSONParser returns a parser of data held in memory. It reads data
+// directly, without a bufio.Reader, so data must not be modified until the
+// parser is done.
+func NewBytesJSONParser(data []byte, loopProp string) *JsonParser {
+
+	j := NewJSONParser(nil, loopProp)
+	j.buf = data
+	return j
+
+}
+
+// NewStringJSONParser returns a parser of s which reads it without copying
+func NewStringJSONParser(s string, loopProp string) *JsonParser {
+
+	return NewBytesJSONParser(unsafe.Slice(unsafe.StringData(s), len(s)), loopProp) // never written
+
+}
+
 func (j *JsonParser) SkipProps(skipProps []string) *JsonParser {
 
 	if len(skipProps) > 0 {
@@ -65,8 +152,139 @@ func (j *JsonParser) SkipProps(skipProps []string) *JsonParser {
 
 }
 
+// TopLevelValues makes the parser stream every top-level value of the input,
+// whatever its type, when the loop property is empty. Values may follow each
+// other directly or be separated by whitespace, e.g. `{"a":1}{"a":2} 3 "s"`.
+func (j *JsonParser) TopLevelValues() *JsonParser {
+
+	j.isTopLevel = true
+	return j
+
+}
+
+// JSONSeq makes the parser read RFC 7464 JSON text sequences
+// (application/json-seq) in which every text is prefixed by an RS (0x1E)
+// byte. Each text is sent as a whole and the loop property is ignored.
+// Truncated or otherwise invalid texts are sent as errors and skipped.
+func (j *JsonParser) JSONSeq() *JsonParser {
+
+	j.isSeq = true
+	return j
+
+}
+
+// ObjectMembers makes the parser stream each member of an object found at the
+// loop property, or of the top level object when the loop property is empty,
+// as a separate result carrying the member name in Key.
+func (j *JsonParser) ObjectMembers() *JsonParser {
+
+	j.isMembers = true
+	return j
+
+}
+
+// Positions makes the parser fill Path, Index, Start and End of every result
+func (j *JsonParser) Positions() *JsonParser {
+
+	j.isPositions = true
+	return j
+
+}
+
+// Raw makes the parser send results as unparsed bytes in Raw, together with
+// their ValueType, instead of building StringVal, ArrayVals or ObjectVals.
+func (j *JsonParser) Raw() *JsonParser {
+
+	j.isRaw = true
+	return j
+
+}
+
+// CaptureProps makes the parser remember the given properties found while
+// looking for the loop property, e.g. the id of an order preceding its lines,
+// and attach them to every following result of the same enclosing object as
+// Context. Values are stored the same way as in ObjectVals.
+func (j *JsonParser) CaptureProps(captureProps []string) *JsonParser {
+
+	if len(captureProps) > 0 {
+		if j.captureProps == nil {
+			j.captureProps = map[string]bool{}
+		}
+		for _, s := range captureProps {
+			j.captureProps[s] = true
+		}
+	}
+	return j
+
+}
+
+// Lazy makes the parser keep arrays and objects nested in each result as
+// unparsed bytes. They are parsed on first access through Object, Array, Get
+// or At and the parsed values are kept, so until then their ObjectVals and
+// ArrayVals are nil. A result must not be accessed concurrently.
+func (j *JsonParser) Lazy() *JsonParser {
+
+	j.isLazy = true
+	return j
+
+}
+
+// MaterializeDepth makes the parser build only the first levels of each
+// result, the result itself being level 1. Arrays and objects below are sent
+// as unparsed bytes in Raw, together with their ValueType, and can be decoded
+// with another parser or encoding/json when needed.
+func (j *JsonParser) MaterializeDepth(levels int) *JsonParser {
+
+	j.maxDepth = levels
+	return j
+
+}
+
+// Tape makes the parser send each result as a compact Tape of tokens in
+// Tape, together with its ValueType, instead of building StringVal,
+// ArrayVals or ObjectVals. Use Tape.Root to navigate it or Tape.JSON to
+// convert it to a tree when needed.
+func (j *JsonParser) Tape() *JsonParser {
+
+	j.isTape = true
+	return j
+
+}
+
+// Pooled makes the parser take results and their nested values from a pool.
+// Call Release on each result when done with it so that it can be reused.
+func (j *JsonParser) Pooled() *JsonParser {
+
+	j.isPooled = true
+	return j
+
+}
+
+// Parallel makes the parser decode results on the given number of worker
+// goroutines. The input is still read on a single goroutine which only splits
+// it into raw values. Results are sent in input order unless Unordered is set.
+func (j *JsonParser) Parallel(workers int) *JsonParser {
+
+	j.workers = workers
+	return j
+
+}
+
+// Unordered lets a parallel parser send results as soon as they are decoded
+func (j *JsonParser) Unordered() *JsonParser {
+
+	j.isUnordered = true
+	return j
+
+}
+
 func (j *JsonParser) Stream() chan *JSON {
 
+	j.isResArr = false
+	j.batches = nil
+	j.forEach = nil
+	j.resChan = make(chan *JSON, j.chanSize)
+
 	go j.parse()
 
 	return j.resChan
@@ -76,34 +294,105 @@ func (j *JsonParser) Stream() chan *JSON {
 func (j *JsonParser) Parse() []*JSON {
 
 	j.isResArr = true
+	j.batches = nil
+	j.forEach = nil
 	j.parse()
 	return j.scratch.allRes()
 
 }
 
+// Reset makes the parser read a new input from reader, keeping its
+// configuration, e.g. SkipProps, and its buffers. The previous input must be
+// parsed completely, and a parser of NewFileJSONParser must be closed first.
+// Results of the previous input stay valid, so parsers can be kept in a
+// sync.Pool and reused for many small inputs.
+func (j *JsonParser) Reset(reader *bufio.Reader) *JsonParser {
+
+	j.reader = reader
+	j.buf = nil
+	j.pos = 0
+	j.base = 0
+	j.TotalReadSize = 0
+	j.sent = 0
+	j.tokenEnd = 0
+	j.root = false
+	j.path = j.path[:0]
+	j.context = nil
+	j.isMapped = false
+	j.scratch.reset()
+	j.scratch.resetRes()
+	return j
+
+}
+
+// ResetBytes makes the parser read data like Reset and NewBytesJSONParser
+func (j *JsonParser) ResetBytes(data []byte) *JsonParser {
+
+	j.Reset(nil)
+	j.buf = data
+	return j
+
+}
+
 func (j *JsonParser) parse() {
 
-	defer close(j.resChan)
+	switch {
+	case j.batches != nil:
+		defer j.batches.close()
+	case j.forEach == nil && !j.isResArr:
+		defer close(j.resChan)
+	}
+	defer j.discard()
+
+	if j.workers > 0 {
+		if j.forEach == nil { // ForEach starts the workers, see parseCollecting
+			j.startWorkers()
+			go j.collect()
+		}
+		defer j.stopWorkers()
+	}
 
 	var b byte
 	var err error
 
-	 
-	if len(j.loopProp)==0{ // expecting top level json is an Array
+	if j.isSeq {
+
+		j.loopSeq()
+		return
+
+	}
+
+	j.tracking = j.isPositions || len(j.captureProps) > 0
+
+	if len(j.loopProp) == 0 && j.isTopLevel {
+
+		j.loopValues()
+		return
+
+	}
+
+	if len(j.loopProp) == 0 { // expecting top level json is an Array
 		for {
 			b, err = j.readByte()
-	
+
 			if err != nil {
 				return
 			}
-	
+
 			if j.isWS(b) {
 				continue
 			}
-	
-			if b == '[' { 
-				
-				j.loopArray()
+
+			if b == '[' {
+
+				j.loopArray("$")
+				return
+
+			}
+
+			if b == '{' && j.isMembers {
+
+				j.loopObject("$")
 				return
 
 			}
@@ -112,113 +401,101 @@ func (j *JsonParser) parse() {
 			return
 
 		}
-	}else{
+	} else {
 
 		for {
 			b, err = j.readByte()
-	
+
 			if err != nil {
 				return
 			}
-	
+
 			if j.isWS(b) {
 				continue
 			}
-	
+
+			if j.tracking {
+				j.trackPath(b)
+			}
+
 			if b == '"' { // begining of possible json property
-	
+
 				isprop, err := j.getPropName()
-	
+
 				if err != nil {
 					j.sendError()
 					return
 				}
-	
+
 				if isprop {
-	
+
+					if j.tracking {
+						j.setPathKey(j.scratch.string())
+					}
+
 					b, err = j.skipWS()
 					if err != nil {
 						j.sendError()
 						return
 					}
-	
+
 					valType, typeErr := j.getValueType(b)
-	
+
 					if typeErr != nil {
 						j.sendError()
 						return
 					}
-	
+
 					if bytes.Equal(j.loopProp, j.scratch.bytes()) {
-	
-						switch valType {
-						case String:
-	
-							err = j.string()
-	
-							if err != nil {
-								j.sendError()
-								return
-							}
-							j.sendRes(&JSON{StringVal: j.scratch.string(), ValueType: String})
-	
-						case Array:
-	
-							success := j.loopArray()
+
+						start := j.offset() - 1
+						path := ""
+						if j.isPositions {
+							path = j.pathString()
+						}
+
+						switch {
+						case valType == Array:
+
+							success := j.loopArray(path)
 							if !success {
 								return
 							}
-	
-						case Object:
-	
-							res := &JSON{ObjectVals: map[string]interface{}{}, ValueType: Object}
-							j.getObjectTree(res)
-							j.sendRes(res)
-							if res.Err != nil {
-								return
-							}
-	
-						case Boolean:
-	
-							b, err := j.boolean()
-							if err != nil {
-								j.sendError()
-								return
-							}
-							j.sendRes(&JSON{BoolVal: b, ValueType: Boolean})
-	
-						case Number:
-	
-							err = j.number(b)
-	
-							if err != nil {
-								j.sendError()
+
+						case valType == Object && j.isMembers:
+
+							success := j.loopObject(path)
+							if !success {
 								return
 							}
-							j.sendRes(&JSON{StringVal: j.scratch.string(), ValueType: Number})
-	
-						case Null:
-	
-							err := j.null()
-	
-							if err != nil {
-								j.sendError()
+
+						default:
+
+							res := j.value(b)
+							res.Context = j.context
+							j.setPosition(res, path, 0, start)
+							if !j.sendRes(res) {
 								return
 							}
-							j.sendRes(&JSON{ValueType: Null})
-	
+
 						}
-	
+
 					} else {
-	
-						if valType == String { // if valtype is string just skip it otherwise continue looking loopProp.
+
+						if j.captureProps[string(j.scratch.bytes())] {
+							if !j.capture(b, valType) {
+								return
+							}
+						} else if valType == String { // if valtype is string just skip it otherwise continue looking loopProp.
 							err = j.skipString()
 							if err != nil {
 								j.sendError()
 								return
 							}
+						} else if j.tracking {
+							j.trackPath(b)
 						}
-	
+
 					}
 				}
 			}
@@ -226,24 +503,185 @@ func (j *JsonParser) parse() {
 
 	}
 
+}
+
+// pathFrame is an enclosing object or array while looking for the loop property
+type pathFrame struct {
+	key      string
+	index    int
+	isArray  bool
+	captured bool
+	prevCtx  map[string]interface{} // context to restore when the frame ends
+}
+
+// trackPath follows the nesting of the input while looking for the loop property
+func (j *JsonParser) trackPath(b byte) {
+
+	switch b {
+	case '{':
+		j.path = append(j.path, pathFrame{})
+	case '[':
+		j.path = append(j.path, pathFrame{isArray: true})
+	case ',':
+		if n := len(j.path); n > 0 && j.path[n-1].isArray {
+			j.path[n-1].index++
+		}
+	case '}', ']':
+		if n := len(j.path); n > 0 {
+			if j.path[n-1].captured {
+				j.context = j.path[n-1].prevCtx
+			}
+			j.path = j.path[:n-1]
+		}
+	}
+
+}
+
+// capture parses the value of a captured property starting with b and adds
+// it to the context of the innermost object. Since the context is shared by
+// results already sent, a new map is created for every change.
+func (j *JsonParser) capture(b byte, valType ValueType) bool {
+
+	prop := j.scratch.string()
+
+	res := j.treeValue(b, valType)
+
+	if res.Err != nil {
+		j.sendRes(res)
+		return false
+	}
+
+	ctx := make(map[string]interface{}, len(j.context)+1)
+	for k, v := range j.context {
+		ctx[k] = v
+	}
+	ctx[prop] = res.objectVal()
+
+	if n := len(j.path); n > 0 && !j.path[n-1].captured {
+		j.path[n-1].captured = true
+		j.path[n-1].prevCtx = j.context
+	}
+	j.context = ctx
+
+	return true
+
+}
+
+// objectVal returns r the way it is stored in ObjectVals
+func (r *JSON) objectVal() interface{} {
+
+	switch r.ValueType {
+	case String, Number:
+		return r.StringVal
+	case Boolean:
+		return r.BoolVal
+	case Null:
+		return ""
+	}
+	return r
+
+}
+
+// setPathKey records the property name of the innermost object
+func (j *JsonParser) setPathKey(key string) {
+
+	if n := len(j.path); n > 0 && !j.path[n-1].isArray {
+		j.path[n-1].key = key
+	}
+
+}
+
+// pathString returns the JSON path of the current position
+func (j *JsonParser) pathString() string {
+
+	path := []byte{'$'}
+	for _, f := range j.path {
+		if f.isArray {
+			path = append(path, '[')
+			path = strconv.AppendInt(path, int64(f.index), 10)
+			path = append(path, ']')
+		} else {
+			path = appendPathKey(path, f.key)
+		}
+	}
+	return string(path)
+
+}
+
+// appendPathKey appends a property to a JSON path in dot or bracket notation
+func appendPathKey(path []byte, key string) []byte {
+
+	ident := len(key) > 0
+	for i := 0; i < len(key) && ident; i++ {
+		c := key[i]
+		ident = c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9')
+	}
+
+	if ident {
+		path = append(path, '.')
+		path = append(path, key...)
+	} else {
+		path = append(path, '[')
+		path = strconv.AppendQuote(path, key)
+		path = append(path, ']')
+	}
+	return path
+
+}
 
+// setPosition records where res was found when positions are enabled
+func (j *JsonParser) setPosition(res *JSON, path string, index int, start uint64) {
+
+	if !j.isPositions || res.Err != nil {
+		return
+	}
 
+	res.Path = path
+	res.Index = index
+	res.Start = start
+	res.End = j.offset()
+	if res.ValueType == Number || res.ValueType == Boolean || res.ValueType == Null {
+		res.End = j.tokenEnd // scalars are followed by a peeked delimiter
+	}
+
+}
+
+// sendRes sends res to the caller, or to the decoding workers of a parallel
+// parser, and reports whether res is not an error and ForEach was not
+// aborted. res must not be used afterwards.
+func (j *JsonParser) sendRes(res *JSON) bool {
+	j.TotalReadSize = j.offset()
+	size := j.TotalReadSize - j.sent // input read for res
+	j.sent = j.TotalReadSize
+	ok := res.Err == nil
+	if j.workers > 0 {
+		j.dispatch(res, size)
+	} else {
+		j.deliver(res, size)
+	}
+	return ok && !j.aborted.Load()
 }
 
-func (j *JsonParser) sendRes(res *JSON) {
-	if j.isResArr {
+// deliver hands res, read from size bytes of input, over to Parse, Stream,
+// StreamBatches or ForEach
+func (j *JsonParser) deliver(res *JSON, size uint64) {
+	if j.forEach != nil {
+		j.visit(res)
+	} else if j.batches != nil {
+		j.batches.add(res, size)
+	} else if j.isResArr {
 		j.scratch.addRes(res)
 	} else {
 		j.resChan <- res
 	}
 }
 
-func (j *JsonParser) loopArray() bool {
+func (j *JsonParser) loopArray(path string) bool {
 
 	var b byte
 	var err error
 
-	for {
+	for index := 0; ; {
 
 		b, err = j.skipWS()
 
@@ -256,65 +694,491 @@ func (j *JsonParser) loopArray() bool {
 			return true
 		}
 
-		if b == ',' {
-			continue
+		if b == ',' {
+			continue
+		}
+
+		start := j.offset() - 1
+		res := j.value(b)
+		res.Context = j.context
+		if j.isPositions {
+			j.setPosition(res, path+"["+strconv.Itoa(index)+"]", index, start)
+		}
+		if !j.sendRes(res) {
+			return false
+		}
+		index++
+
+	}
+
+}
+
+// loopObject sends each member of an object as a separate result with its Key
+func (j *JsonParser) loopObject(path string) bool {
+
+	var b byte
+	var err error
+
+	for index := 0; ; {
+
+		b, err = j.skipWS()
+
+		if err != nil {
+			j.sendError()
+			return false
+		}
+
+		if b == '}' {
+			return true
+		}
+
+		if b == ',' {
+			continue
+		}
+
+		if b != '"' {
+			j.sendError()
+			return false
+		}
+
+		isprop, err := j.getPropName()
+
+		if err != nil || !isprop {
+			j.sendError()
+			return false
+		}
+
+		key := j.scratch.string()
+
+		b, err = j.skipWS()
+
+		if err != nil {
+			j.sendError()
+			return false
+		}
+
+		start := j.offset() - 1
+		res := j.value(b)
+		res.Key = key
+		res.Context = j.context
+		if j.isPositions {
+			j.setPosition(res, string(appendPathKey([]byte(path), key)), index, start)
+		}
+		if !j.sendRes(res) {
+			return false
+		}
+		index++
+
+	}
+
+}
+
+// loopValues sends each value of a sequence of top-level json values
+func (j *JsonParser) loopValues() {
+
+	for index := 0; ; index++ {
+
+		b, err := j.skipWS()
+
+		if err != nil {
+			if err != io.EOF {
+				j.sendError()
+			}
+			return
+		}
+
+		start := j.offset() - 1
+		res := j.rootValue(b)
+		j.setPosition(res, "$", index, start)
+		if !j.sendRes(res) {
+			return
+		}
+
+	}
+
+}
+
+// record separator of json text sequences
+const rs = 0x1E
+
+// loopSeq sends each text of a json text sequence
+func (j *JsonParser) loopSeq() {
+
+	index := 0
+
+	for first := true; ; first = false {
+
+		offset := j.offset()
+		rec, err := j.readRecord()
+
+		if err != nil && err != io.EOF {
+			j.sendError()
+			return
+		}
+
+		if first && len(bytes.Trim(rec, " \t\n\r")) > 0 { // garbage before first RS
+			j.sendErrorStr("Truncated json text")
+		} else if !first {
+			res := j.seqValue(rec, offset)
+			if res != nil {
+				if j.isPositions && res.Err == nil {
+					res.Index = index
+				}
+				j.sendRes(res)
+				index++
+			}
+		}
+
+		if err == io.EOF || j.aborted.Load() {
+			return
+		}
+
+	}
+
+}
+
+// readRecord reads the input up to the next RS. Returned record excludes the RS.
+func (j *JsonParser) readRecord() ([]byte, error) {
+
+	j.seqBuf = j.seqBuf[:0]
+
+	for {
+
+		i := bytes.IndexByte(j.buf[j.pos:], rs)
+
+		if i >= 0 {
+			j.seqBuf = append(j.seqBuf, j.buf[j.pos:j.pos+i]...)
+			j.pos += i + 1
+			return j.seqBuf, nil
+		}
+
+		j.seqBuf = append(j.seqBuf, j.buf[j.pos:]...)
+		j.pos = len(j.buf)
+
+		err := j.fill()
+		if err != nil {
+			return j.seqBuf, err
+		}
+
+	}
+
+}
+
+// seqValue parses a single text of a json text sequence. Empty texts return nil.
+func (j *JsonParser) seqValue(rec []byte, offset uint64) *JSON {
+
+	if j.seqText == nil {
+		j.seqText = &JsonParser{
+			skipProps:    j.skipProps,
+			isPositions:  j.isPositions,
+			isRaw:        j.isRaw,
+			isTape:       j.isTape,
+			isLazy:       j.isLazy,
+			maxDepth:     j.maxDepth,
+			maxNesting:   j.maxNesting,
+			maxValueSize: j.maxValueSize,
+			scratch:      j.scratch,
+		}
+	}
+
+	t := j.seqText // reads rec only
+	t.buf = rec
+	t.pos = 0
+	t.base = offset
+
+	b, err := t.skipWS()
+
+	if err == io.EOF { // multiple RS are ignored
+		return nil
+	}
+
+	start := t.offset() - 1
+	res := t.rootValue(b)
+	t.setPosition(res, "$", 0, start)
+
+	if res.Err != nil {
+		return &JSON{Err: errors.New("Truncated json text"), ValueType: Invalid}
+	}
+
+	// top level numbers and literals not followed by whitespace are possibly truncated
+	if res.ValueType == Number || res.ValueType == Boolean || res.ValueType == Null {
+		c, err := t.readByte()
+		if err != nil || !t.isWS(c) {
+			return &JSON{Err: errors.New("Truncated json text"), ValueType: Invalid}
+		}
+	}
+
+	_, err = t.skipWS()
+	if err != io.EOF {
+		return &JSON{Err: errors.New("Invalid json text"), ValueType: Invalid}
+	}
+
+	return res
+
+}
+
+// rootValue parses a single top-level value starting with b
+func (j *JsonParser) rootValue(b byte) *JSON {
+
+	// scalars are terminated differently at the top level
+	j.root = b != '{' && b != '['
+	res := j.value(b)
+	j.root = false
+	return res
+
+}
+
+// value parses a single value starting with b
+func (j *JsonParser) value(b byte) *JSON {
+
+	valType, err := j.getValueType(b)
+
+	if err != nil {
+		return &JSON{Err: err, ValueType: Invalid}
+	}
+
+	start := j.offset() - 1
+
+	if j.maxValueSize > 0 {
+		j.limit = start + uint64(j.maxValueSize)
+	}
+
+	var res *JSON
+	switch {
+	case j.isRaw || j.workers > 0: // parallel parsers decode raw values on workers
+		res = j.rawValue(b, valType)
+	case j.isTape:
+		res = j.tapeValue(b, valType)
+	default:
+		res = j.treeValue(b, valType)
+	}
+
+	j.limit = 0
+
+	// scalars may still end past the limit
+	if j.maxValueSize > 0 && res.Err == nil {
+		end := j.offset()
+		if valType == Number || valType == Boolean || valType == Null {
+			end = j.tokenEnd
+		}
+		if end-start > uint64(j.maxValueSize) {
+			res.Release()
+			return &JSON{Err: errSize, ValueType: Invalid}
+		}
+	}
+
+	return res
+
+}
+
+// treeValue parses a single value of type valType starting with b
+func (j *JsonParser) treeValue(b byte, valType ValueType) *JSON {
+
+	var err error
+
+	switch valType {
+	case String:
+
+		s, err := j.stringValue()
+		if err != nil {
+			return &JSON{Err: err, ValueType: Invalid}
+		}
+		res := j.newJSON(String)
+		res.StringVal = s
+		return res
+
+	case Array:
+
+		res := j.newJSON(Array)
+		j.getArrayTree(res)
+		return res
+
+	case Object:
+
+		res := j.newJSON(Object)
+		j.getObjectTree(res)
+		return res
+
+	case Boolean:
+
+		bl, err := j.boolean()
+		if err != nil {
+			return &JSON{Err: err, ValueType: Invalid}
+		}
+		res := j.newJSON(Boolean)
+		res.BoolVal = bl
+		return res
+
+	case Number:
+
+		err = j.number(b)
+		if err != nil {
+			return &JSON{Err: err, ValueType: Invalid}
+		}
+		res := j.newJSON(Number)
+		res.StringVal = j.scratch.string()
+		return res
+
+	}
+
+	err = j.null()
+	if err != nil {
+		return &JSON{Err: err, ValueType: Invalid}
+	}
+	return j.newJSON(Null)
+
+}
+
+// rawValue copies a single value starting with b without parsing it
+func (j *JsonParser) rawValue(b byte, valType ValueType) *JSON {
+
+	if j.isZeroCopy(valType) {
+		return j.mappedRaw(b, valType)
+	}
+
+	var err error
+
+	switch valType {
+	case String:
+
+		j.scratch.reset()
+		j.scratch.add(b)
+		err = j.rawString()
+
+	case Array, Object:
+
+		j.scratch.reset()
+		j.scratch.add(b)
+		err = j.rawArrayOrObject()
+
+	case Number:
+
+		err = j.number(b) // number keeps its text in scratch
+
+	case Boolean:
+
+		var bl bool
+		bl, err = j.boolean()
+		j.scratch.reset()
+		if bl {
+			j.scratch.addString("true")
+		} else {
+			j.scratch.addString("false")
+		}
+
+	case Null:
+
+		err = j.null()
+		j.scratch.reset()
+		j.scratch.addString("null")
+
+	}
+
+	if err != nil {
+		return &JSON{Err: err, ValueType: Invalid}
+	}
+
+	return j.newRaw(valType, j.scratch.bytes())
+
+}
+
+// rawString appends the rest of a string including the closing quote to scratch
+func (j *JsonParser) rawString() error {
+
+	for {
+
+		i := j.pos
+		for i < len(j.buf) && !stringStop[j.buf[i]] {
+			i++
+		}
+		if j.overLimit(i) {
+			return errSize
+		}
+		j.scratch.addBytes(j.buf[j.pos:i])
+		j.pos = i
+
+		if i == len(j.buf) {
+			err := j.fill()
+			if err != nil {
+				return j.defaultError()
+			}
+			continue
+		}
+
+		c := j.buf[i]
+		j.pos++
+		j.scratch.add(c)
+
+		if c == '"' {
+			return nil
 		}
 
-		valType, err := j.getValueType(b)
+		if c != '\\' {
+			return j.defaultError()
+		}
 
+		c, err := j.readByte() // escaped byte is copied as is
 		if err != nil {
-			j.sendError()
-			return false
+			return j.defaultError()
 		}
+		j.scratch.add(c)
 
-		switch valType {
-		case String:
+	}
 
-			err = j.string()
+}
 
-			if err != nil {
-				j.sendRes(&JSON{Err: err, ValueType: Invalid})
-				return false
-			}
-			j.sendRes(&JSON{StringVal: j.scratch.string(), ValueType: String})
-		case Array:
+// rawArrayOrObject appends the rest of an array or object to scratch
+func (j *JsonParser) rawArrayOrObject() error {
 
-			res := &JSON{ObjectVals: map[string]interface{}{}, ValueType: Array}
-			j.getArrayTree(res)
-			j.sendRes(res)
+	var depth = 1
 
-		case Object:
+	for {
 
-			res := &JSON{ObjectVals: map[string]interface{}{}, ValueType: Object}
-			j.getObjectTree(res)
-			j.sendRes(res)
+		from := j.pos
 
-		case Boolean:
+		for j.pos < len(j.buf) {
 
-			b, err := j.boolean()
-			if err != nil {
-				j.sendError()
-				return false
+			i := j.pos + indexStructural(j.buf[j.pos:])
+			if i == len(j.buf) {
+				j.pos = i
+				break
 			}
-			j.sendRes(&JSON{BoolVal: b, ValueType: Boolean})
 
-		case Number:
+			c := j.buf[i]
+			j.pos = i + 1
 
-			err = j.number(b)
-			if err != nil {
-				return false
+			if j.overLimit(j.pos) {
+				return errSize
 			}
-			j.sendRes(&JSON{StringVal: j.scratch.string(), ValueType: Number})
 
-		case Null:
+			switch c {
+			case '"':
+				j.scratch.addBytes(j.buf[from:j.pos])
+				err := j.rawString() // this is needed because string can contain brackets
+				if err != nil {
+					return err
+				}
+				from = j.pos
+			case '[', '{':
+				depth++
+			case ']', '}':
+				depth--
+				if depth == 0 {
+					j.scratch.addBytes(j.buf[from:j.pos])
+					return nil
+				}
+			}
 
-			err := j.null()
+		}
 
-			if err != nil {
-				return false
-			}
-			j.sendRes(&JSON{ValueType: Null})
+		if j.overLimit(j.pos) {
+			return errSize
+		}
+		j.scratch.addBytes(j.buf[from:])
 
+		err := j.fill()
+		if err != nil {
+			return j.defaultError()
 		}
 
 	}
@@ -338,6 +1202,11 @@ func (j *JsonParser) getObjectTree(res *JSON) {
 			return
 		}
 
+		if j.overLimit(j.pos) {
+			res.Err = errSize
+			return
+		}
+
 		if j.isWS(b) {
 			continue
 		}
@@ -345,7 +1214,7 @@ func (j *JsonParser) getObjectTree(res *JSON) {
 		if b == '"' { // begining of json property
 
 			_, err := j.getPropName() // first variable ommited because inside object there can't be string item
-			prop := j.scratch.string()
+			prop := j.internKey()
 
 			if err != nil {
 				res.Err = err
@@ -378,14 +1247,14 @@ func (j *JsonParser) getObjectTree(res *JSON) {
 					break
 				}
 
-				err = j.string()
+				s, err := j.stringValue()
 
 				if err != nil {
 					res.Err = err
 					return
 				}
 
-				res.ObjectVals[prop] = j.scratch.string()
+				res.ObjectVals[prop] = s
 
 			case Array:
 
@@ -398,8 +1267,7 @@ func (j *JsonParser) getObjectTree(res *JSON) {
 					}
 					break
 				}
-				r := &JSON{ValueType: Array}
-				j.getArrayTree(r)
+				r := j.nestedValue(b, Array)
 				if r.Err != nil {
 					res.Err = r.Err
 					return
@@ -417,8 +1285,7 @@ func (j *JsonParser) getObjectTree(res *JSON) {
 					}
 					break
 				}
-				r := &JSON{ObjectVals: map[string]interface{}{}, ValueType: Object}
-				j.getObjectTree(r)
+				r := j.nestedValue(b, Object)
 
 				if r.Err != nil {
 					res.Err = r.Err
@@ -504,6 +1371,11 @@ func (j *JsonParser) getArrayTree(res *JSON) {
 			return
 		}
 
+		if j.overLimit(j.pos) {
+			res.Err = errSize
+			return
+		}
+
 		if j.isWS(b) {
 			continue
 		}
@@ -525,18 +1397,17 @@ func (j *JsonParser) getArrayTree(res *JSON) {
 		switch valType {
 		case String:
 
-			err = j.string()
+			s, err := j.stringValue()
 
 			if err != nil {
 				res.Err = err
 				return
 			}
-			res.ArrayVals = append(res.ArrayVals, j.scratch.string())
+			res.ArrayVals = append(res.ArrayVals, s)
 
 		case Array:
 
-			r := &JSON{ValueType: Array}
-			j.getArrayTree(r)
+			r := j.nestedValue(b, Array)
 			if r.Err != nil {
 				res.Err = r.Err
 				return
@@ -545,8 +1416,7 @@ func (j *JsonParser) getArrayTree(res *JSON) {
 
 		case Object:
 
-			r := &JSON{ObjectVals: map[string]interface{}{}, ValueType: Object}
-			j.getObjectTree(r)
+			r := j.nestedValue(b, Object)
 			if r.Err != nil {
 				res.Err = r.Err
 				return
@@ -591,52 +1461,97 @@ func (j *JsonParser) getArrayTree(res *JSON) {
 
 func (j *JsonParser) number(first byte) error {
 
-	var c byte
-	var err error
 	j.scratch.reset()
 	j.scratch.add(first)
 
 	for {
 
-		c, err = j.readByte()
+		// copy the number in bulk up to the first delimiter
+		i := j.pos
+		for i < len(j.buf) && !j.isNumberEnd(j.buf[i]) {
+			i++
+		}
+		if j.overLimit(i) {
+			return errSize
+		}
+		j.scratch.addBytes(j.buf[j.pos:i])
+		j.pos = i
+
+		if i < len(j.buf) {
+			return j.scalarEnd()
+		}
+
+		err := j.fill()
 
 		if err != nil {
+			if err == io.EOF && j.root {
+				j.tokenEnd = j.offset()
+				return nil
+			}
 			return j.defaultError()
 		}
 
-		if j.isWS(c) {
+	}
 
-			c, err = j.skipWS()
+}
 
-			if err != nil {
-				return j.defaultError()
-			}
+// isNumberEnd reports whether c delimits a number
+func (j *JsonParser) isNumberEnd(c byte) bool {
 
-			if !(c == ',' || c == '}' || c == ']') {
-				return j.defaultError()
-			}
-			err := j.unreadByte()
-			if err != nil {
-				return j.defaultError()
-			}
+	return j.isWS(c) || c == ',' || c == '}' || c == ']' || (j.root && j.isValueStart(c))
 
-			return nil
-		}
+}
 
-		if c == ',' || c == '}' || c == ']' {
+// scalarEnd checks the bytes following a number or literal without consuming
+// the next token. Inside containers a separator or closing bracket must
+// follow, at the top level whitespace, EOF or the start of another value.
+func (j *JsonParser) scalarEnd() error {
 
-			err := j.unreadByte()
-			if err != nil {
-				return j.defaultError()
-			}
+	j.tokenEnd = j.offset()
 
+	if j.root {
+
+		c, err := j.readByte()
+
+		if err == io.EOF {
 			return nil
 		}
 
-		j.scratch.add(c)
+		if err != nil || !(j.isWS(c) || j.isValueStart(c)) {
+			return j.defaultError()
+		}
+
+		err = j.unreadByte()
+		if err != nil {
+			return j.defaultError()
+		}
+
+		return nil
+	}
+
+	c, err := j.skipWS()
+	if err != nil {
+		return j.defaultError()
+	}
+
+	if !(c == ',' || c == '}' || c == ']') {
+		return j.defaultError()
+	}
 
+	err = j.unreadByte()
+	if err != nil {
+		return j.defaultError()
 	}
 
+	return nil
+
+}
+
+// isValueStart reports whether c unambiguously starts a new value after a scalar
+func (j *JsonParser) isValueStart(c byte) bool {
+
+	return c == '{' || c == '[' || c == '"'
+
 }
 
 func (j *JsonParser) boolean() (bool, error) {
@@ -665,16 +1580,9 @@ func (j *JsonParser) boolean() (bool, error) {
 			}
 			if c == 'e' {
 				// check last
-				c, err = j.skipWS()
-				if err != nil {
-					return false, j.defaultError()
-				}
-				if !(c == ',' || c == '}' || c == ']') {
-					return false, j.defaultError()
-				}
-				err := j.unreadByte()
+				err = j.scalarEnd()
 				if err != nil {
-					return false, j.defaultError()
+					return false, err
 				}
 
 				return true, nil
@@ -703,16 +1611,9 @@ func (j *JsonParser) boolean() (bool, error) {
 				}
 				if c == 'e' {
 					// check last
-					c, err = j.skipWS()
-					if err != nil {
-						return false, j.defaultError()
-					}
-					if !(c == ',' || c == '}' || c == ']') {
-						return false, j.defaultError()
-					}
-					err := j.unreadByte()
+					err = j.scalarEnd()
 					if err != nil {
-						return false, j.defaultError()
+						return false, err
 					}
 
 					return false, nil
@@ -752,21 +1653,7 @@ func (j *JsonParser) null() error {
 			}
 			if c == 'l' {
 				// check last
-				c, err = j.skipWS()
-				if err != nil {
-					return j.defaultError()
-				}
-
-				if !(c == ',' || c == '}' || c == ']') {
-					return j.defaultError()
-				}
-
-				err := j.unreadByte()
-				if err != nil {
-					return j.defaultError()
-				}
-
-				return nil
+				return j.scalarEnd()
 			}
 		}
 	}
@@ -776,62 +1663,133 @@ func (j *JsonParser) null() error {
 
 func (j *JsonParser) skipString() error {
 
-	var c byte
-	var prev byte
-	var prevPrev byte
-	var err error
+	backslashes := 0 // run of backslashes preceding the window
+
 	for {
 
-		c, err = j.readByte()
+		s := j.buf[j.pos:]
+		i := bytes.IndexByte(s, '"')
 
-		if err != nil {
-			return j.defaultError()
-		}
+		if i < 0 {
 
-		if c == '"' {
+			backslashes = trailingBackslashes(s, backslashes)
+			j.pos = len(j.buf)
 
-			if !(prev == '\\' && prevPrev != '\\') { // escape check
-				return nil
+			err := j.fill()
+			if err != nil {
+				return j.defaultError()
 			}
+			continue
 
 		}
 
-		prevPrev = prev
-		prev = c
+		// the quote is escaped by an odd number of backslashes
+		escaped := trailingBackslashes(s[:i], backslashes)%2 == 1
+		j.pos += i + 1
+
+		if !escaped {
+			return nil
+		}
+
+		backslashes = 0
 
 	}
 
 }
 
+// trailingBackslashes returns the length of the run of backslashes ending s,
+// which continues a run of n backslashes preceding s
+func trailingBackslashes(s []byte, n int) int {
+
+	i := len(s)
+	for i > 0 && s[i-1] == '\\' {
+		i--
+	}
+
+	if i == 0 {
+		return n + len(s)
+	}
+	return len(s) - i
+
+}
+
+// skipArrayOrObject skips the rest of an array or object. Buffered bytes are
+// scanned a word at a time for quotes and brackets only, strings are skipped
+// with skipString.
 func (j *JsonParser) skipArrayOrObject(start byte, end byte) error {
 
-	var c byte
-	var err error
-	var depth = 1
+	ps, pe := swarLo*uint64(start), swarLo*uint64(end)
+	depth := 1
+
+scan:
 	for {
 
-		c, err = j.readByte()
+		s := j.buf[j.pos:]
+		i := 0
 
-		if err != nil {
-			return j.defaultError()
-		}
+		for i+8 <= len(s) {
+
+			w := swarWord(s, i)
+			marks := swarEq(w, swarQuote) | swarEq(w, ps) | swarEq(w, pe)
+
+			for marks != 0 {
+
+				k := swarIndex(marks)
+				marks &= marks - 1
+
+				switch s[i+k] {
+				case '"':
+					j.pos += i + k + 1
+					err := j.skipString() // this is needed because string can contain [ or ]
+					if err != nil {
+						return err
+					}
+					continue scan
+				case start:
+					depth++
+				default:
+					depth--
+					if depth == 0 {
+						j.pos += i + k + 1
+						return nil
+					}
+				}
 
-		switch c {
-		case '"':
-			err = j.skipString() // this is needed because string can contain [ or ]
-			if err != nil {
-				return err
 			}
-		case start:
-			depth++
-		case end:
-			depth--
-			if depth == 0 {
-				return nil
+
+			i += 8
+
+		}
+
+		for ; i < len(s); i++ {
+
+			switch s[i] {
+			case '"':
+				j.pos += i + 1
+				err := j.skipString()
+				if err != nil {
+					return err
+				}
+				continue scan
+			case start:
+				depth++
+			case end:
+				depth--
+				if depth == 0 {
+					j.pos += i + 1
+					return nil
+				}
 			}
 
 		}
 
+		j.pos = len(j.buf)
+
+		err := j.fill()
+		if err != nil {
+			return j.defaultError()
+		}
+
 	}
 
 }
@@ -884,6 +1842,42 @@ func (j *JsonParser) getPropName() (bool, error) {
 
 }
 
+// bounds of the property name interning table
+const (
+	maxInternedKeys   = 4096
+	maxInternedKeyLen = 128
+)
+
+// internKey returns the property name in scratch. Names repeated across
+// results share a single string. The table is cleared when full so that
+// objects keyed by ids don't grow it without limit.
+func (j *JsonParser) internKey() string {
+
+	b := j.scratch.bytes()
+
+	if key, ok := j.keys[string(b)]; ok {
+		return key
+	}
+
+	key := string(b)
+
+	if len(b) > maxInternedKeyLen {
+		return key
+	}
+
+	if j.keys == nil {
+		j.keys = make(map[string]string)
+	} else if len(j.keys) >= maxInternedKeys {
+		for k := range j.keys {
+			delete(j.keys, k)
+		}
+	}
+
+	j.keys[key] = key
+	return key
+
+}
+
 func (j *JsonParser) isWS(in byte) bool {
 
 	if in == ' ' || in == '\n' || in == '\t' || in == '\r' {
@@ -897,64 +1891,96 @@ func (j *JsonParser) isWS(in byte) bool {
 // skips WS and read first non WS
 func (j *JsonParser) skipWS() (byte, error) {
 
-	var b byte
-	var err error
 	for {
-		b, err = j.readByte()
+
+		for j.pos < len(j.buf) {
+			b := j.buf[j.pos]
+			j.pos++
+			if !(b == ' ' || b == '\n' || b == '\t' || b == '\r') {
+				return b, nil
+			}
+		}
+
+		err := j.fill()
 		if err != nil {
 			return 0, err
 		}
-		if b == ' ' || b == '\n' || b == '\t' || b == '\r' {
-			continue
-		} else {
-			return b, nil
-		}
+
 	}
 
 }
 
 func (j *JsonParser) readByte() (byte, error) {
 
-	by, err := j.reader.ReadByte()
+	if j.pos == len(j.buf) {
+		err := j.fill()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	by := j.buf[j.pos]
+	j.pos++
+	return by, nil
 
-	j.TotalReadSize = j.TotalReadSize + 1
+}
 
-	j.lastReadSize = 1
+func (j *JsonParser) unreadByte() error {
 
-	if err != nil {
-		return 0, err
+	if j.pos == 0 {
+		return bufio.ErrInvalidUnreadByte
 	}
-	return by, nil
+	j.pos--
+	return nil
 
 }
 
-func (j *JsonParser) unreadByte() error {
+// offset returns the position of the next byte in the input
+func (j *JsonParser) offset() uint64 {
+
+	return j.base + uint64(j.pos)
 
-	err := j.reader.UnreadByte()
+}
+
+// fill replaces the consumed window with the next bytes buffered by reader.
+// Scanning works on these windows directly instead of calling ReadByte.
+func (j *JsonParser) fill() error {
+
+	if j.reader == nil {
+		return io.EOF
+	}
+
+	j.discard()
+
+	_, err := j.reader.Peek(1)
 	if err != nil {
 		return err
 	}
-	j.TotalReadSize = j.TotalReadSize - 1
+
+	j.buf, _ = j.reader.Peek(j.reader.Buffered())
 	return nil
 
 }
 
-func (j *JsonParser) sendError() {
-	err := fmt.Errorf("Invalid json")
-	if j.isResArr {
-		j.scratch.addRes(&JSON{Err: err, ValueType: Invalid})
-	} else {
-		j.resChan <- &JSON{Err: err, ValueType: Invalid}
+// discard advances reader past the consumed part of the window
+func (j *JsonParser) discard() {
+
+	if j.reader != nil && j.pos > 0 {
+		j.reader.Discard(j.pos)
 	}
+	j.base += uint64(j.pos)
+	j.TotalReadSize = j.base
+	j.buf = nil
+	j.pos = 0
+
+}
+
+func (j *JsonParser) sendError() {
+	j.sendRes(&JSON{Err: j.defaultError(), ValueType: Invalid})
 }
 
 func (j *JsonParser) sendErrorStr(s string) {
-	err := fmt.Errorf(s)
-	if j.isResArr {
-		j.scratch.addRes(&JSON{Err: err, ValueType: Invalid})
-	} else {
-		j.resChan <- &JSON{Err: err, ValueType: Invalid}
-	}
+	j.sendRes(&JSON{Err: errors.New(s), ValueType: Invalid})
 }
 
 func (j *JsonParser) resultError() *JSON {
@@ -976,8 +2002,11 @@ func (j *JsonParser) string() error {
 	var err error
 	var c byte
 
-	c, err = j.readByte()
+	c, err = j.stringRun()
 	if err != nil {
+		if err == errSize {
+			return err
+		}
 		if err != nil {
 			return j.defaultError()
 		}
@@ -1002,8 +2031,11 @@ scan:
 
 		}
 		j.scratch.add(c)
-		c, err = j.readByte()
+		c, err = j.stringRun()
 		if err != nil {
+			if err == errSize {
+				return err
+			}
 			if err != nil {
 				return j.defaultError()
 			}
@@ -1090,6 +2122,46 @@ scan_u:
 	goto scan
 }
 
+// stringStop marks the bytes ending a run of plain string bytes
+var stringStop = func() (stop [256]bool) {
+	for c := 0; c < 0x20; c++ {
+		stop[c] = true
+	}
+	stop['"'] = true
+	stop['\\'] = true
+	return
+}()
+
+// stringRun copies the following plain string bytes to scratch in bulk and
+// reads the byte after them
+func (j *JsonParser) stringRun() (byte, error) {
+
+	for {
+
+		i := j.pos
+		for i < len(j.buf) && !stringStop[j.buf[i]] {
+			i++
+		}
+		if j.overLimit(i) {
+			return 0, errSize
+		}
+		j.scratch.addBytes(j.buf[j.pos:i])
+
+		if i < len(j.buf) {
+			j.pos = i + 1
+			return j.buf[i], nil
+		}
+
+		j.pos = i
+		err := j.fill()
+		if err != nil {
+			return 0, err
+		}
+
+	}
+
+}
+
 // u4 reads four bytes following a \u escape
 func (j *JsonParser) u4() rune {
 	// logic taken from:
@@ -3,10 +3,22 @@ package jsparser
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
+	"errors"
 	"flag"
+	"io"
 	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+	"unsafe"
 )
 
 var minify bool
@@ -456,6 +468,1548 @@ func TestRootArray(t *testing.T) {
 
 }
 
+func TestTopLevelValues(t *testing.T) {
+
+	values := `{"Name": "Ed"}{"Name": "Sam"}
+		["a", 1] "Hello World"	666 -1.5e3{"Name":"Go"}
+		null true false`
+
+	br := bufio.NewReader(bytes.NewReader([]byte(values)))
+	p := NewJSONParser(br, "").TopLevelValues()
+	var results []*JSON
+	for _, json := range allResult(p) {
+
+		if json.Err != nil {
+			t.Fatal(json.Err)
+		}
+		results = append(results, json)
+	}
+
+	if len(results) != 10 {
+		t.Fatal("result count must be 10")
+	}
+
+	if results[0].ObjectVals["Name"].(string) != "Ed" || results[1].ObjectVals["Name"].(string) != "Sam" {
+		t.Fatal("concatenated objects Test failed")
+	}
+
+	if results[2].ValueType != Array || len(results[2].ArrayVals) != 2 {
+		t.Fatal("results[2] Test failed")
+	}
+
+	if results[3].StringVal != "Hello World" {
+		t.Fatal("results[3] Test failed")
+	}
+
+	if results[4].StringVal != "666" || results[5].StringVal != "-1.5e3" {
+		t.Fatal("numbers Test failed")
+	}
+
+	if results[6].ObjectVals["Name"].(string) != "Go" {
+		t.Fatal("results[6] Test failed")
+	}
+
+	if results[7].ValueType != Null || !results[8].BoolVal || results[9].BoolVal || results[9].ValueType != Boolean {
+		t.Fatal("literals Test failed")
+	}
+
+	// bare scalar
+	br = bufio.NewReader(bytes.NewReader([]byte("42")))
+	p = NewJSONParser(br, "").TopLevelValues()
+	results = allResult(p)
+
+	if len(results) != 1 || results[0].Err != nil || results[0].StringVal != "42" {
+		t.Fatal("bare scalar Test failed")
+	}
+
+	// invalid
+	br = bufio.NewReader(bytes.NewReader([]byte(`{"a":1} truex`)))
+	p = NewJSONParser(br, "").TopLevelValues()
+	results = allResult(p)
+
+	if len(results) != 2 || results[0].Err != nil || results[1].Err == nil {
+		t.Fatal("Invalid error expected")
+	}
+
+}
+
+func TestJSONSeq(t *testing.T) {
+
+	seq := "\x1e{\"Name\": \"Ed\"}\n" +
+		"\x1e{\"Name\": \"Sa" + // truncated
+		"\x1e\x1e123\n" +
+		"\x1e456" + // truncated number
+		"\x1e\"Hello World\"\n" +
+		"\x1etrue\x1e" + // truncated literal
+		"\x1e[1,2]\n"
+
+	br := bufio.NewReader(strings.NewReader(seq))
+	p := NewJSONParser(br, "").JSONSeq()
+	results := allResult(p)
+
+	if len(results) != 7 {
+		t.Fatal("result count must be 7")
+	}
+
+	if results[0].Err != nil || results[0].ObjectVals["Name"].(string) != "Ed" {
+		t.Fatal("results[0] Test failed")
+	}
+
+	if results[1].Err == nil || results[3].Err == nil || results[5].Err == nil {
+		t.Fatal("Truncated error expected")
+	}
+
+	if results[2].Err != nil || results[2].StringVal != "123" {
+		t.Fatal("results[2] Test failed")
+	}
+
+	if results[4].Err != nil || results[4].StringVal != "Hello World" {
+		t.Fatal("results[4] Test failed")
+	}
+
+	if results[6].Err != nil || len(results[6].ArrayVals) != 2 {
+		t.Fatal("results[6] Test failed")
+	}
+
+}
+
+func TestObjectMembers(t *testing.T) {
+
+	byID := `{"byId": {"123": {"Name": "Ed"}, "456": {"Name": "Sam"}, "789": "text", "0": [1, 2]}}`
+
+	br := bufio.NewReader(strings.NewReader(byID))
+	p := NewJSONParser(br, "byId").ObjectMembers()
+	var results []*JSON
+	for _, json := range allResult(p) {
+
+		if json.Err != nil {
+			t.Fatal(json.Err)
+		}
+		results = append(results, json)
+	}
+
+	if len(results) != 4 {
+		t.Fatal("result count must be 4")
+	}
+
+	if results[0].Key != "123" || results[0].ObjectVals["Name"].(string) != "Ed" {
+		t.Fatal("results[0] Test failed")
+	}
+
+	if results[1].Key != "456" || results[1].ObjectVals["Name"].(string) != "Sam" {
+		t.Fatal("results[1] Test failed")
+	}
+
+	if results[2].Key != "789" || results[2].StringVal != "text" {
+		t.Fatal("results[2] Test failed")
+	}
+
+	if results[3].Key != "0" || len(results[3].ArrayVals) != 2 {
+		t.Fatal("results[3] Test failed")
+	}
+
+	// top level object
+	br = bufio.NewReader(strings.NewReader(`{"a": 1, "b": {"c": true}}`))
+	p = NewJSONParser(br, "").ObjectMembers()
+	results = allResult(p)
+
+	if len(results) != 2 || results[0].Key != "a" || results[0].StringVal != "1" || results[1].Key != "b" || results[1].ObjectVals["c"] != true {
+		t.Fatal("top level object Test failed")
+	}
+
+}
+
+func TestPositions(t *testing.T) {
+
+	orders := `{"orders": [{"id": 1, "lines": ["a", {"b": 2}]}, {"id": 2, "lines": [true, 12 ]}]}`
+
+	br := bufio.NewReader(strings.NewReader(orders))
+	p := NewJSONParser(br, "lines").Positions()
+	results := allResult(p)
+
+	if len(results) != 4 {
+		t.Fatal("result count must be 4")
+	}
+
+	paths := []string{"$.orders[0].lines[0]", "$.orders[0].lines[1]", "$.orders[1].lines[0]", "$.orders[1].lines[1]"}
+	texts := []string{`"a"`, `{"b": 2}`, "true", "12"}
+
+	for i, json := range results {
+
+		if json.Err != nil {
+			t.Fatal(json.Err)
+		}
+
+		if json.Path != paths[i] {
+			t.Fatalf("results[%d] invalid path %s", i, json.Path)
+		}
+
+		if json.Index != i%2 {
+			t.Fatalf("results[%d] invalid index %d", i, json.Index)
+		}
+
+		if orders[json.Start:json.End] != texts[i] {
+			t.Fatalf("results[%d] invalid span %q", i, orders[json.Start:json.End])
+		}
+	}
+
+	// members and top level values
+	byID := `{"byId": {"1 2": {"Name": "Ed"}, "x": null}}`
+	br = bufio.NewReader(strings.NewReader(byID))
+	p = NewJSONParser(br, "byId").ObjectMembers().Positions()
+	results = allResult(p)
+
+	if results[0].Path != `$.byId["1 2"]` || results[1].Path != "$.byId.x" || results[1].Index != 1 {
+		t.Fatal("members Test failed")
+	}
+
+	if byID[results[1].Start:results[1].End] != "null" {
+		t.Fatal("members span Test failed")
+	}
+
+	values := `{"a":1} 42`
+	br = bufio.NewReader(strings.NewReader(values))
+	p = NewJSONParser(br, "").TopLevelValues().Positions()
+	results = allResult(p)
+
+	if results[1].Index != 1 || values[results[1].Start:results[1].End] != "42" {
+		t.Fatal("top level values Test failed")
+	}
+
+}
+
+func TestRaw(t *testing.T) {
+
+	p := getparser("a").Raw()
+	var results []*JSON
+
+	for _, js := range allResult(p) {
+
+		if js.Err != nil {
+			t.Fatal(js.Err)
+		}
+
+		var v interface{}
+		if err := json.Unmarshal(json.RawMessage(js.Raw), &v); err != nil {
+			t.Fatalf("invalid raw value %s", js.Raw)
+		}
+		results = append(results, js)
+	}
+
+	if len(results) != 7 {
+		t.Fatal("result count must 7")
+	}
+
+	types := []ValueType{Object, Object, String, Boolean, Number, Null, Number}
+	for i, js := range results {
+		if js.ValueType != types[i] || js.ObjectVals != nil || js.StringVal != "" {
+			t.Fatalf("results[%d] Test failed", i)
+		}
+	}
+
+	if string(results[2].Raw) != `"astringinside"` || string(results[3].Raw) != "false" || string(results[5].Raw) != "null" {
+		t.Fatal("raw scalar Test failed")
+	}
+
+	list := `{"list": [{"Name": "Ed", "Text": "[{\\\"}]"} , ["a", {"b": []}]]}`
+	br := bufio.NewReader(strings.NewReader(list))
+	p = NewJSONParser(br, "list").Raw()
+	results = allResult(p)
+
+	if len(results) != 2 || string(results[0].Raw) != `{"Name": "Ed", "Text": "[{\\\"}]"}` || string(results[1].Raw) != `["a", {"b": []}]` {
+		t.Fatal("raw container Test failed")
+	}
+
+}
+
+func TestCaptureProps(t *testing.T) {
+
+	orders := `{"source": "shop", "orders": [
+		{"orderId": 1, "customer": {"name": "Ed"}, "lines": [{"sku": "a"}, {"sku": "b"}]},
+		{"orderId": 2, "note": "no customer", "lines": [{"sku": "c"}]}
+	]}`
+
+	br := bufio.NewReader(strings.NewReader(orders))
+	p := NewJSONParser(br, "lines").CaptureProps([]string{"orderId", "customer", "source"})
+	results := allResult(p)
+
+	if len(results) != 3 {
+		t.Fatal("result count must be 3")
+	}
+
+	for _, json := range results {
+		if json.Err != nil {
+			t.Fatal(json.Err)
+		}
+		if json.Context["source"].(string) != "shop" {
+			t.Fatal("outer context Test failed")
+		}
+	}
+
+	if results[0].Context["orderId"].(string) != "1" || results[1].Context["orderId"].(string) != "1" {
+		t.Fatal("results[0] Test failed")
+	}
+
+	if results[1].Context["customer"].(*JSON).ObjectVals["name"].(string) != "Ed" {
+		t.Fatal("results[1] Test failed")
+	}
+
+	if results[2].Context["orderId"].(string) != "2" {
+		t.Fatal("results[2] Test failed")
+	}
+
+	if _, ok := results[2].Context["customer"]; ok {
+		t.Fatal("context of previous order must not leak")
+	}
+
+}
+
+func TestSmallBuffer(t *testing.T) {
+
+	sample, err := os.ReadFile("sample.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsers := []func(br *bufio.Reader) *JsonParser{
+		func(br *bufio.Reader) *JsonParser { return NewJSONParser(br, "o") },
+		func(br *bufio.Reader) *JsonParser { return NewJSONParser(br, "a") },
+		func(br *bufio.Reader) *JsonParser { return NewJSONParser(br, "s2") },
+		func(br *bufio.Reader) *JsonParser { return NewJSONParser(br, "n2").Positions() },
+		func(br *bufio.Reader) *JsonParser { return NewJSONParser(br, "a").SkipProps([]string{"a11", "a12"}) },
+		func(br *bufio.Reader) *JsonParser { return NewJSONParser(br, "o").Raw() },
+		func(br *bufio.Reader) *JsonParser { return NewJSONParser(br, "a").Raw().Positions() },
+		func(br *bufio.Reader) *JsonParser { return NewJSONParser(br, "").TopLevelValues() },
+	}
+
+	for i, newParser := range parsers {
+
+		// smallest buffer bufio allows makes every value cross windows
+		small := allResult(newParser(bufio.NewReaderSize(bytes.NewReader(sample), 16)))
+		large := allResult(newParser(bufio.NewReader(bytes.NewReader(sample))))
+
+		if len(small) == 0 || !reflect.DeepEqual(small, large) {
+			t.Fatalf("parser %d: results differ with small buffer", i)
+		}
+	}
+
+}
+
+func TestSkipEscapes(t *testing.T) {
+
+	// string values ending in runs of backslashes, with quotes escaped by odd runs
+	list := `{"list": [
+		{"skip": "x\\\"}", "keep": "k1"},
+		{"skip": "a\\\\", "keep": "k2"},
+		{"skip": ["\\\"]", {"q": "\\\\\\\"{"}, "\\"], "keep": "k3"},
+		{"skip": {"a": "\\\\\"}\\\\"}, "keep": "k4"}
+	]}`
+
+	other := `{"other": "\\\", \"list\": [\"fake\"] \\\\", "list": ["real"]}`
+
+	for size := 16; size < 48; size++ { // move runs of backslashes across buffer boundaries
+
+		br := bufio.NewReaderSize(strings.NewReader(list), size)
+		p := NewJSONParser(br, "list").SkipProps([]string{"skip"})
+		results := allResult(p)
+
+		if len(results) != 4 {
+			t.Fatalf("buffer %d: result count must be 4", size)
+		}
+
+		for i, json := range results {
+			if json.Err != nil {
+				t.Fatalf("buffer %d: %v", size, json.Err)
+			}
+			if _, ok := json.ObjectVals["skip"]; ok || json.ObjectVals["keep"].(string) != "k"+strconv.Itoa(i+1) {
+				t.Fatalf("buffer %d: results[%d] Test failed", size, i)
+			}
+		}
+
+		// skipped string of a non matching property
+		br = bufio.NewReaderSize(strings.NewReader(other), size)
+		p = NewJSONParser(br, "list")
+		results = allResult(p)
+
+		if len(results) != 1 || results[0].Err != nil || results[0].StringVal != "real" {
+			t.Fatalf("buffer %d: non matching property Test failed", size)
+		}
+	}
+
+}
+
+// plain converts results to basic go values for comparison
+func plain(v interface{}) interface{} {
+
+	js, ok := v.(*JSON)
+	if !ok {
+		return v
+	}
+
+	switch js.ValueType {
+	case Object:
+		m := map[string]interface{}{}
+		for k, val := range js.Object() {
+			m[k] = plain(val)
+		}
+		return m
+	case Array:
+		a := []interface{}{}
+		for _, val := range js.Array() {
+			a = append(a, plain(val))
+		}
+		return a
+	case Boolean:
+		return js.BoolVal
+	case Null:
+		return nil
+	}
+	return js.StringVal
+
+}
+
+func TestPooled(t *testing.T) {
+
+	for _, prop := range []string{"o", "a"} {
+
+		var expected []interface{}
+		for _, json := range allResult(getparser(prop)) {
+			expected = append(expected, plain(json))
+		}
+
+		for round := 0; round < 3; round++ { // later rounds reuse released results
+
+			var results []interface{}
+			for _, json := range allResult(getparser(prop).Pooled()) {
+				if json.Err != nil {
+					t.Fatal(json.Err)
+				}
+				results = append(results, plain(json))
+				json.Release()
+			}
+
+			if !reflect.DeepEqual(expected, results) {
+				t.Fatalf("%s round %d: pooled results differ", prop, round)
+			}
+		}
+
+		// raw values
+		for round := 0; round < 3; round++ {
+			for i, json := range allResult(getparser(prop).Raw().Pooled()) {
+				if i == 0 && len(json.Raw) == 0 {
+					t.Fatal("raw value expected")
+				}
+				json.Release()
+			}
+		}
+	}
+
+	// results of other parsers are left alone
+	json := allResult(getparser("o"))[0]
+	json.Release()
+	if json.ObjectVals["o1"].(string) != "o1string" {
+		t.Fatal("Release must not affect results of not pooled parsers")
+	}
+
+}
+
+func TestInternKeys(t *testing.T) {
+
+	list := `{"list": [{"Name": "Ed", "Text": {"Name": "Go"}}, {"Name": "Sam", "Text": "Who's there?"}]}`
+
+	br := bufio.NewReader(strings.NewReader(list))
+	p := NewJSONParser(br, "list")
+	results := allResult(p)
+
+	keyData := func(m map[string]interface{}, key string) *byte {
+		for k := range m {
+			if k == key {
+				return unsafe.StringData(k)
+			}
+		}
+		return nil
+	}
+
+	name := keyData(results[0].ObjectVals, "Name")
+
+	if name == nil || keyData(results[1].ObjectVals, "Name") != name {
+		t.Fatal("property names must be shared between results")
+	}
+
+	if keyData(results[0].ObjectVals["Text"].(*JSON).ObjectVals, "Name") != name {
+		t.Fatal("property names must be shared between nested objects")
+	}
+
+}
+
+func TestParallel(t *testing.T) {
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"list": [`)
+	for i := 0; i < 1000; i++ {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		switch i % 4 {
+		case 0:
+			buf.WriteString(`{"id": ` + strconv.Itoa(i) + `, "tags": ["a", "b"], "skip": {"x": 1}}`)
+		case 1:
+			buf.WriteString(`"item ` + strconv.Itoa(i) + `"`)
+		case 2:
+			buf.WriteString(strconv.Itoa(i))
+		default:
+			buf.WriteString(`[true, null, {"id": ` + strconv.Itoa(i) + `}]`)
+		}
+	}
+	buf.WriteString(`]}`)
+	input := buf.Bytes()
+
+	newParser := func() *JsonParser {
+		return NewJSONParser(bufio.NewReader(bytes.NewReader(input)), "list").SkipProps([]string{"skip"}).Positions()
+	}
+
+	var expected []*JSON
+	for _, json := range allResult(newParser()) {
+		expected = append(expected, json)
+	}
+
+	results := allResult(newParser().Parallel(4))
+
+	if len(results) != 1000 || !reflect.DeepEqual(expected, results) {
+		t.Fatal("parallel results must equal sequential results")
+	}
+
+	results = allResult(newParser().Parallel(4).Unordered())
+	sort.Slice(results, func(a, b int) bool { return results[a].Index < results[b].Index })
+
+	if len(results) != 1000 || !reflect.DeepEqual(expected, results) {
+		t.Fatal("unordered parallel results must equal sequential results")
+	}
+
+	// errors end ordered results like in sequential parsing
+	invalid := `{"list": [{"Name": "Ed"}, {"Name": tru}, {"Name": "Sam"}]}`
+	results = allResult(NewJSONParser(bufio.NewReader(strings.NewReader(invalid)), "list").Parallel(2))
+
+	if len(results) != 2 || results[0].Err != nil || results[1].Err == nil {
+		t.Fatal("Invalid error expected")
+	}
+
+}
+
+func TestTape(t *testing.T) {
+
+	expected := allResult(getparser("a").SkipProps([]string{"a13"}))
+	results := allResult(getparser("a").SkipProps([]string{"a13"}).Tape())
+
+	if len(results) != 7 || len(expected) != 7 {
+		t.Fatal("result count must 7")
+	}
+
+	for i, js := range results {
+		if js.Err != nil {
+			t.Fatal(js.Err)
+		}
+		if js.Tape == nil || js.ValueType != expected[i].ValueType || js.ObjectVals != nil {
+			t.Fatalf("results[%d] Test failed", i)
+		}
+		if !reflect.DeepEqual(expected[i], js.Tape.JSON()) {
+			t.Fatalf("results[%d] tape must convert to the tree", i)
+		}
+	}
+
+	root := results[0].Tape.Root()
+
+	if v, ok := root.Get("a14"); !ok || v.Type() != Number || v.String() != "98" {
+		t.Fatal("Get Test failed")
+	}
+
+	if _, ok := root.Get("a13"); ok {
+		t.Fatal("skipped property must not be in the tape")
+	}
+
+	a12, _ := root.Get("a12")
+	if v, ok := a12.Index(2); !ok || v.Type() != Boolean || v.Bool() {
+		t.Fatal("Index Test failed")
+	}
+
+	if v, ok := a12.Index(4); !ok || v.Type() != Object {
+		t.Fatal("Index Test failed")
+	} else if _, ok := v.Child(); ok {
+		t.Fatal("empty object must not have a child")
+	}
+
+	if _, ok := a12.Index(5); ok {
+		t.Fatal("Index out of range Test failed")
+	}
+
+	var keys []string
+	for m, ok := root.Child(); ok; m, ok = m.Next() {
+		keys = append(keys, m.Key())
+	}
+
+	if strings.Join(keys, ",") != "a11,a12,a14" {
+		t.Fatal("member iteration Test failed", keys)
+	}
+
+	if v := results[2].Tape.Root(); v.Type() != String || v.String() != "astringinside" {
+		t.Fatal("scalar Test failed")
+	}
+
+	// objects followed by sibling members and elements
+	nested := `{"list": [[{"x": [{"a": 1}, {"b": 2}]}], [{"a": 1}, 2], {"o": {"a": 1}, "b": 2}, [{}, 1], {"e": {}, "f": [{}], "g": 3}]}`
+	expected = allResult(NewStringJSONParser(nested, "list"))
+	results = allResult(NewStringJSONParser(nested, "list").Tape())
+
+	for i, js := range results {
+		if !reflect.DeepEqual(expected[i], js.Tape.JSON()) {
+			t.Fatalf("nested[%d] tape must convert to the tree", i)
+		}
+	}
+
+	root = results[2].Tape.Root()
+	if o, _ := root.Get("o"); o.Type() != Object {
+		t.Fatal("nested Get Test failed")
+	} else if m, ok := o.Child(); !ok || m.Key() != "a" {
+		t.Fatal("nested member Test failed")
+	} else if _, ok := m.Next(); ok {
+		t.Fatal("member iteration must end at the end of the object")
+	}
+
+	if b, ok := root.Get("b"); !ok || b.String() != "2" {
+		t.Fatal("member following an object Test failed")
+	}
+
+	keys = nil
+	e, _ := results[4].Tape.Root().Get("e")
+	for m, ok := e.Child(); ok; m, ok = m.Next() {
+		keys = append(keys, m.Key())
+	}
+
+	if len(keys) != 0 {
+		t.Fatal("empty object must not have members", keys)
+	}
+
+	// workers build tapes as well
+	expected = allResult(getparser("a").Tape())
+	results = allResult(getparser("a").Tape().Parallel(2))
+
+	if !reflect.DeepEqual(expected, results) {
+		t.Fatal("parallel tape results must equal sequential results")
+	}
+
+}
+
+func TestLazy(t *testing.T) {
+
+	expected := allResult(getparser("a").SkipProps([]string{"a13", "o41"}))
+	results := allResult(getparser("a").SkipProps([]string{"a13", "o41"}).Lazy())
+
+	if len(results) != 7 {
+		t.Fatal("result count must 7")
+	}
+
+	a12, ok := results[0].ObjectVals["a12"].(*JSON)
+	if !ok || a12.ValueType != Array || a12.ArrayVals != nil || !strings.HasPrefix(string(a12.Raw), "[") {
+		t.Fatal("nested value must be kept raw")
+	}
+
+	if a12.At(0) != "o72string" || a12.At(3) != "98" || a12.At(5) != nil || a12.Raw != nil {
+		t.Fatal("At Test failed")
+	}
+
+	if o, ok := a12.At(4).(*JSON); !ok || o.ValueType != Object || len(o.Object()) != 0 {
+		t.Fatal("nested object Test failed")
+	}
+
+	if a12.At(4) != a12.At(4) {
+		t.Fatal("parsed values must be kept")
+	}
+
+	for i, js := range results {
+		if js.Err != nil {
+			t.Fatal(js.Err)
+		}
+		if !reflect.DeepEqual(plain(expected[i]), plain(js)) {
+			t.Fatalf("results[%d] must equal the tree", i)
+		}
+	}
+
+	// nested values are only checked when accessed
+	invalid := `{"list": [{"a": {"b": tru}}]}`
+	results = allResult(NewJSONParser(bufio.NewReader(strings.NewReader(invalid)), "list").Lazy())
+
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatal("nested value must not be parsed")
+	}
+
+	a := results[0].Get("a").(*JSON)
+	if a.Get("b") != nil || a.Err == nil {
+		t.Fatal("Invalid error expected")
+	}
+
+	// pooled lazy values are recycled as well
+	for json := range getparser("a").Lazy().Pooled().Stream() {
+		plain(json)
+		json.Release()
+	}
+
+}
+
+func TestMaterializeDepth(t *testing.T) {
+
+	list := `{"list": [{"id": 1, "a": {"b": {"c": [1, {"d": "e"}]}, "f": [true, []]}}, [[1], 2]]}`
+
+	newParser := func() *JsonParser {
+		return NewJSONParser(bufio.NewReader(strings.NewReader(list)), "list")
+	}
+
+	expected := allResult(newParser())
+
+	for levels := 1; levels <= 4; levels++ {
+
+		results := allResult(newParser().MaterializeDepth(levels))
+
+		if len(results) != 2 {
+			t.Fatal("result count must be 2")
+		}
+
+		for i, js := range results {
+			if js.Err != nil {
+				t.Fatal(js.Err)
+			}
+			if checkDepth(plain(expected[i]), js, 1, levels) {
+				t.Fatalf("results[%d] Test failed with %d levels", i, levels)
+			}
+		}
+
+	}
+
+}
+
+// checkDepth reports whether the value v of a result materialized up to the
+// given levels differs from the expected plain value
+func checkDepth(expected interface{}, v interface{}, level int, levels int) bool {
+
+	js, ok := v.(*JSON)
+	if !ok || (js.ValueType != Object && js.ValueType != Array) {
+		return !reflect.DeepEqual(expected, plain(v))
+	}
+
+	if level > levels {
+		var decoded interface{}
+		if js.ObjectVals != nil || js.ArrayVals != nil || json.Unmarshal(js.Raw, &decoded) != nil {
+			return true
+		}
+		return !reflect.DeepEqual(normalized(expected), decoded)
+	}
+
+	if js.Raw != nil {
+		return true
+	}
+
+	if js.ValueType == Object {
+		m := expected.(map[string]interface{})
+		if len(m) != len(js.ObjectVals) {
+			return true
+		}
+		for k, val := range js.ObjectVals {
+			if checkDepth(m[k], val, level+1, levels) {
+				return true
+			}
+		}
+		return false
+	}
+
+	a := expected.([]interface{})
+	if len(a) != len(js.ArrayVals) {
+		return true
+	}
+	for i, val := range js.ArrayVals {
+		if checkDepth(a[i], val, level+1, levels) {
+			return true
+		}
+	}
+	return false
+
+}
+
+// normalized converts a plain value to the types of encoding/json
+func normalized(v interface{}) interface{} {
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for k, val := range v {
+			m[k] = normalized(val)
+		}
+		return m
+	case []interface{}:
+		a := []interface{}{}
+		for _, val := range v {
+			a = append(a, normalized(val))
+		}
+		return a
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return v
+
+}
+
+func TestFileParser(t *testing.T) {
+
+	file := func(prop string) *JsonParser {
+		p, err := NewFileJSONParser("sample.json", prop)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	open := func(prop string) *JsonParser {
+		f, _ := os.Open("sample.json")
+		t.Cleanup(func() { f.Close() })
+		return NewJSONParser(bufio.NewReader(f), prop)
+	}
+
+	p := file("a").SkipProps([]string{"a13"}).Positions()
+	results := allResult(p)
+
+	if !reflect.DeepEqual(allResult(open("a").SkipProps([]string{"a13"}).Positions()), results) {
+		t.Fatal("file results must equal reader results")
+	}
+
+	// unescaped strings reference the mapping, escaped ones are copied
+	inMapping := func(s string) bool {
+		start := uintptr(unsafe.Pointer(unsafe.SliceData(p.mapped)))
+		ptr := uintptr(unsafe.Pointer(unsafe.StringData(s)))
+		return ptr >= start && ptr < start+uintptr(len(p.mapped))
+	}
+
+	if !inMapping(results[2].StringVal) || !inMapping(results[1].ObjectVals["a11"].(string)) || inMapping(results[0].ObjectVals["a11"].(string)) {
+		t.Fatal("strings must not be copied")
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	p = file("a").Raw()
+	results = allResult(p)
+	expected := allResult(open("a").Raw())
+
+	if !reflect.DeepEqual(expected, results) || string(results[0].Raw) != string(expected[0].Raw) {
+		t.Fatal("file raw results must equal reader results")
+	}
+	p.Close()
+
+	p = file("o").Parallel(2)
+	if !reflect.DeepEqual(allResult(open("o")), allResult(p)) {
+		t.Fatal("parallel file results must equal reader results")
+	}
+	p.Close()
+
+	if _, err := NewFileJSONParser("missing.json", "a"); err == nil {
+		t.Fatal("error expected")
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.json")
+	os.WriteFile(empty, nil, 0644)
+	p, err := NewFileJSONParser(empty, "a")
+	if err != nil || len(allResult(p)) != 0 || p.Close() != nil {
+		t.Fatal("empty file Test failed")
+	}
+
+}
+
+func TestConstructors(t *testing.T) {
+
+	data, _ := os.ReadFile("sample.json")
+
+	f, _ := os.Open("sample.json")
+	defer f.Close()
+	expected := allResult(NewJSONParser(bufio.NewReader(f), "a").Positions())
+
+	parsers := map[string]*JsonParser{
+		"reader":       NewReaderJSONParser(bytes.NewReader(data), "a", 0),
+		"small reader": NewReaderJSONParser(bytes.NewReader(data), "a", 16),
+		"bytes":        NewBytesJSONParser(data, "a"),
+		"string":       NewStringJSONParser(string(data), "a"),
+	}
+
+	for name, p := range parsers {
+		if !reflect.DeepEqual(expected, allResult(p.Positions())) {
+			t.Fatal(name + " results must equal bufio.Reader results")
+		}
+		if p.TotalReadSize != uint64(len(data)) {
+			t.Fatal(name + " invalid TotalReadSize")
+		}
+	}
+
+	results := allResult(NewStringJSONParser(`[1, "a", {"b": [true]}]`, "").TopLevelValues())
+	if len(results) != 1 || len(results[0].ArrayVals) != 3 {
+		t.Fatal("string Test failed")
+	}
+
+	if len(allResult(NewBytesJSONParser(nil, "a"))) != 0 || len(allResult(NewStringJSONParser("", "a"))) != 0 {
+		t.Fatal("empty input Test failed")
+	}
+
+}
+
+func TestReset(t *testing.T) {
+
+	first := `{"list": [{"id": 1, "skip": "x"}, {"id": 2, "skip": [1]}]}`
+	second := `{"other": 1, "list": ["a", {"id": 3, "skip": {}}]}`
+
+	fresh := func(input string) []interface{} {
+		var res []interface{}
+		for _, json := range NewStringJSONParser(input, "list").SkipProps([]string{"skip"}).Parse() {
+			res = append(res, plain(json))
+		}
+		return res
+	}
+
+	p := NewStringJSONParser(first, "list").SkipProps([]string{"skip"})
+	results := p.Parse()
+
+	var before []interface{}
+	for _, json := range results {
+		before = append(before, plain(json))
+	}
+
+	if !reflect.DeepEqual(fresh(first), before) {
+		t.Fatal("first input Test failed")
+	}
+
+	var streamed []interface{}
+	for json := range p.Reset(bufio.NewReader(strings.NewReader(second))).Stream() {
+		streamed = append(streamed, plain(json))
+	}
+
+	if !reflect.DeepEqual(fresh(second), streamed) || p.TotalReadSize != uint64(len(second)) {
+		t.Fatal("Reset Test failed")
+	}
+
+	var parsed []interface{}
+	for _, json := range p.ResetBytes([]byte(second)).Parse() {
+		parsed = append(parsed, plain(json))
+	}
+
+	if !reflect.DeepEqual(fresh(second), parsed) {
+		t.Fatal("ResetBytes Test failed")
+	}
+
+	// results of previous inputs are not reused
+	var after []interface{}
+	for _, json := range results {
+		after = append(after, plain(json))
+	}
+
+	if !reflect.DeepEqual(before, after) {
+		t.Fatal("previous results must stay valid")
+	}
+
+}
+
+func TestOptions(t *testing.T) {
+
+	data, _ := os.ReadFile("sample.json")
+
+	expected := NewBytesJSONParser(data, "a").SkipProps([]string{"a13"}).Positions().Parse()
+
+	p, err := NewParser(bytes.NewReader(data), WithLoopPath("a"), WithSkip("a13"), WithPositions(),
+		WithChannelBuffer(0), WithScratchSize(1), WithReadBuffer(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(expected, allResult(p)) {
+		t.Fatal("option results must equal chained results")
+	}
+
+	invalid := [][]Option{
+		{WithParallel(-1)},
+		{WithUnordered()},
+		{WithRaw(), WithTape()},
+		{WithLazy(), WithMaterializeDepth(2)},
+		{WithTopLevelValues(), WithLoopPath("a")},
+		{WithJSONSeq(), WithLoopPath("a")},
+		{WithJSONSeq(), WithTopLevelValues()},
+		{WithObjectMembers(), WithTopLevelValues()},
+		{WithChannelBuffer(-1)},
+		{WithScratchSize(0)},
+		{WithLimits(Limits{MaxDepth: -1})},
+	}
+
+	for i, opts := range invalid {
+		if _, err := NewParser(bytes.NewReader(data), opts...); err == nil {
+			t.Fatalf("invalid[%d] error expected", i)
+		}
+	}
+
+	limited := func(input string, limits Limits, opts ...Option) []*JSON {
+		p, err := NewParser(strings.NewReader(input), append(opts, WithLoopPath("list"), WithLimits(limits))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return allResult(p)
+	}
+
+	nested := `{"list": [{"a": [1]}, {"a": [[1]]}, 3]}`
+
+	for _, opts := range [][]Option{nil, {WithTape()}, {WithParallel(2)}} {
+
+		if results := limited(nested, Limits{MaxDepth: 3}, opts...); len(results) != 3 || results[1].Err != nil {
+			t.Fatal("depth within the limit Test failed")
+		}
+
+		if results := limited(nested, Limits{MaxDepth: 2}, opts...); len(results) != 2 || results[0].Err != nil || results[1].Err == nil {
+			t.Fatal("depth limit error expected")
+		}
+
+	}
+
+	// lazy values are checked when they are materialized
+	deep := `{"list": [{"a": {"b": {"c": {"d": 1}}}}]}`
+
+	results := limited(deep, Limits{MaxDepth: 2}, WithLazy())
+	a, _ := results[0].Get("a").(*JSON)
+	if len(results) != 1 || a == nil || a.Get("b") != nil || a.Err != errDepth {
+		t.Fatal("lazy depth limit error expected")
+	}
+
+	results = limited(deep, Limits{MaxDepth: 4}, WithLazy())
+	if d := results[0].Get("a").(*JSON).Get("b").(*JSON).Get("c").(*JSON).Get("d"); d != "1" {
+		t.Fatal("lazy depth within the limit Test failed")
+	}
+
+	if results := limited(deep, Limits{MaxDepth: 1}, WithLazy()); len(results) != 1 || results[0].Err != errDepth {
+		t.Fatal("lazy depth limit error expected")
+	}
+
+	sized := `{"list": ["abc", 12345, "abcd", 0]}`
+	if results := limited(sized, Limits{MaxValueSize: 5}); len(results) != 3 || results[1].Err != nil || results[2].Err == nil {
+		t.Fatal("size limit error expected")
+	}
+
+	// large values end with an error before they are read completely
+	for _, big := range []string{
+		`{"list": ["` + strings.Repeat("x", 1<<20) + `"]}`,
+		`{"list": [[` + strings.Repeat(`"abc", `, 1<<17) + `0]]}`,
+		`{"list": [{"a": ` + strings.Repeat("1", 1<<20) + `}]}`,
+	} {
+		for _, opts := range [][]Option{nil, {WithRaw()}, {WithTape()}, {WithPositions()}} {
+			for _, inMemory := range []bool{false, true} {
+
+				p, _ := NewParser(strings.NewReader(big), append(opts, WithLoopPath("list"), WithLimits(Limits{MaxValueSize: 10}))...)
+				if inMemory {
+					p.ResetBytes([]byte(big))
+				}
+
+				results := allResult(p)
+				if len(results) != 1 || results[0].Err != errSize || len(p.scratch.data) > 4096 {
+					t.Fatal("size limit error expected while reading", len(p.scratch.data))
+				}
+
+			}
+		}
+	}
+
+}
+
+func TestStreamBatches(t *testing.T) {
+
+	list := `{"list": [{"id": 1}, {"id": 2}, {"id": 3}, {"id": 4}, {"id": 5}, {"id": 6}, {"id": 7}]}`
+
+	newParser := func() *JsonParser {
+		return NewStringJSONParser(list, "list")
+	}
+
+	expected := allResult(newParser())
+
+	batched := func(batches chan []*JSON) ([]*JSON, []int) {
+		var results []*JSON
+		var sizes []int
+		for batch := range batches {
+			results = append(results, batch...)
+			sizes = append(sizes, len(batch))
+		}
+		return results, sizes
+	}
+
+	results, sizes := batched(newParser().StreamBatches(3, 0, 0))
+	if !reflect.DeepEqual(expected, results) || !reflect.DeepEqual(sizes, []int{3, 3, 1}) {
+		t.Fatal("batch size Test failed", sizes)
+	}
+
+	// every item is read from 11 bytes of input, the first one from 19
+	results, sizes = batched(newParser().StreamBatches(0, 30, 0))
+	if !reflect.DeepEqual(expected, results) || !reflect.DeepEqual(sizes, []int{2, 3, 2}) {
+		t.Fatal("batch budget Test failed", sizes)
+	}
+
+	results, sizes = batched(newParser().Parallel(2).StreamBatches(2, 0, 0))
+	if !reflect.DeepEqual(expected, results) || !reflect.DeepEqual(sizes, []int{2, 2, 2, 1}) {
+		t.Fatal("parallel batch Test failed", sizes)
+	}
+
+	// a batch of a slow input is sent after the timeout
+	r, w := io.Pipe()
+	batches := NewJSONParser(bufio.NewReader(r), "list").StreamBatches(100, 0, 10*time.Millisecond)
+
+	go w.Write([]byte(`{"list": [{"id": 1}, {"id": 2}, `))
+
+	select {
+	case batch := <-batches:
+		if len(batch) != 2 {
+			t.Fatal("timeout batch Test failed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch must be sent after the timeout")
+	}
+
+	go func() {
+		w.Write([]byte(`{"id": 3}]}`))
+		w.Close()
+	}()
+
+	results, _ = batched(batches)
+	if len(results) != 1 {
+		t.Fatal("last batch Test failed")
+	}
+
+}
+
+func TestForEach(t *testing.T) {
+
+	expected := allResult(getparser("a"))
+
+	for _, p := range []*JsonParser{getparser("a"), getparser("a").Parallel(2)} {
+
+		var results []*JSON
+		caller := goroutineID()
+		err := p.ForEach(func(json *JSON) error {
+			if goroutineID() != caller {
+				return errors.New("called on another goroutine")
+			}
+			results = append(results, json)
+			return nil
+		})
+
+		if err != nil || !reflect.DeepEqual(expected, results) {
+			t.Fatal("ForEach results must equal streamed results", err)
+		}
+
+		count := 0
+		err = p.Reset(bufio.NewReader(strings.NewReader(`{"a": [1, 2, 3, 4]}`))).ForEach(func(json *JSON) error {
+			count++
+			if count == 2 {
+				return Stop
+			}
+			return nil
+		})
+
+		if err != nil || count != 2 {
+			t.Fatal("Stop Test failed")
+		}
+
+		errFound := errors.New("found")
+		count = 0
+		err = p.Reset(bufio.NewReader(strings.NewReader(`{"a": [1, 2, 3, 4]}`))).ForEach(func(json *JSON) error {
+			count++
+			if json.StringVal == "3" {
+				return errFound
+			}
+			return nil
+		})
+
+		if err != errFound || count != 3 {
+			t.Fatal("error Test failed")
+		}
+
+		// the parser is usable after aborting
+		if results := p.Reset(bufio.NewReader(strings.NewReader(`{"a": [1, 2]}`))).Parse(); len(results) != 2 {
+			t.Fatal("Parse after ForEach Test failed")
+		}
+
+	}
+
+	// parse errors are passed on like in Stream
+	var results []*JSON
+	err := NewStringJSONParser(`{"a": [1, tru]}`, "a").ForEach(func(json *JSON) error {
+		results = append(results, json)
+		return nil
+	})
+
+	if err != nil || len(results) != 2 || results[1].Err == nil {
+		t.Fatal("Invalid error expected")
+	}
+
+}
+
+// goroutineID returns the id of the calling goroutine
+func goroutineID() string {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	return strings.Fields(string(buf))[1] // goroutine 18 [running]:
+}
+
+func TestTokenizer(t *testing.T) {
+
+	input := `{"a": [1, "s\"", true, null, {}], "b": {"c": -2.5e3}} 7 "x"`
+
+	expected := []Token{
+		{Kind: TokenStartObject, Depth: 0, Offset: 0},
+		{Kind: TokenKey, Value: "a", Depth: 1, Offset: 1},
+		{Kind: TokenStartArray, Depth: 1, Offset: 6},
+		{Kind: TokenNumber, Value: "1", Depth: 2, Offset: 7},
+		{Kind: TokenString, Value: `s"`, Depth: 2, Offset: 10},
+		{Kind: TokenBool, Bool: true, Depth: 2, Offset: 17},
+		{Kind: TokenNull, Depth: 2, Offset: 23},
+		{Kind: TokenStartObject, Depth: 2, Offset: 29},
+		{Kind: TokenEndObject, Depth: 2, Offset: 30},
+		{Kind: TokenEndArray, Depth: 1, Offset: 31},
+		{Kind: TokenKey, Value: "b", Depth: 1, Offset: 34},
+		{Kind: TokenStartObject, Depth: 1, Offset: 39},
+		{Kind: TokenKey, Value: "c", Depth: 2, Offset: 40},
+		{Kind: TokenNumber, Value: "-2.5e3", Depth: 2, Offset: 45},
+		{Kind: TokenEndObject, Depth: 1, Offset: 51},
+		{Kind: TokenEndObject, Depth: 0, Offset: 52},
+		{Kind: TokenNumber, Value: "7", Depth: 0, Offset: 54},
+		{Kind: TokenString, Value: "x", Depth: 0, Offset: 56},
+	}
+
+	for _, tk := range []*Tokenizer{NewBytesTokenizer([]byte(input)), NewTokenizer(bufio.NewReaderSize(strings.NewReader(input), 16))} {
+
+		var tokens []Token
+		for {
+			tok, err := tk.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tokens = append(tokens, tok)
+		}
+
+		if !reflect.DeepEqual(expected, tokens) {
+			t.Fatal("tokens Test failed", tokens)
+		}
+
+		if _, err := tk.Next(); err != io.EOF {
+			t.Fatal("EOF expected")
+		}
+
+	}
+
+	invalid := []string{`[1,]`, `{"a": 1,}`, `[1 2]`, `["a" "b"]`, `{"a" 1}`, `{1: 2}`, `[}`, `{]`, `{"a": }`, `[1`, `{"a": [true]`, `tru`, `[,1]`,
+		`[1x]`, `[-]`, `[1.2.3]`, `[01]`, `[1.]`, `[.5]`, `[1e]`, `[1e+]`, `[+1]`, `-`, `2e5x`}
+
+	for _, input := range invalid {
+
+		tk := NewBytesTokenizer([]byte(input))
+		var err error
+		for err == nil {
+			_, err = tk.Next()
+		}
+
+		if err == io.EOF {
+			t.Fatal("Invalid error expected", input)
+		}
+
+		if _, again := tk.Next(); again != err {
+			t.Fatal("error must be kept", input)
+		}
+
+	}
+
+	for _, input := range []string{`0`, `-0`, `10.25`, `1E+2`, `-0.5e-10`} {
+		if tok, err := NewBytesTokenizer([]byte(input)).Next(); err != nil || tok.Kind != TokenNumber || tok.Value != input {
+			t.Fatal("number Test failed", input)
+		}
+	}
+
+}
+
+func TestPushParser(t *testing.T) {
+
+	data, _ := os.ReadFile("sample.json")
+	expected := NewBytesJSONParser(data, "a").Positions().Parse()
+
+	for _, size := range []int{1, 7, 64, len(data)} {
+
+		var results []*JSON
+		p, err := NewPushParser(func(json *JSON) error {
+			results = append(results, json)
+			return nil
+		}, WithLoopPath("a"), WithPositions())
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < len(data); i += size {
+			if n, err := p.Write(data[i:min(i+size, len(data))]); err != nil || n != min(size, len(data)-i) {
+				t.Fatal("Write Test failed", err)
+			}
+		}
+
+		if err := p.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(expected, results) {
+			t.Fatal("push results must equal parsed results", size)
+		}
+
+	}
+
+	var results []*JSON
+	p, _ := NewPushParser(func(json *JSON) error {
+		results = append(results, json)
+		if json.StringVal == "stop" {
+			return Stop
+		}
+		return nil
+	}, WithLoopPath("list"))
+
+	// results are passed on during Write
+	p.Write([]byte(`{"list": [{"id": 1}, {"id"`))
+	if len(results) != 1 {
+		t.Fatal("complete result expected after Write")
+	}
+
+	p.Write([]byte(`: 2}, 3`))
+	if len(results) != 2 {
+		t.Fatal("number must be complete with its delimiter")
+	}
+
+	if err := p.Flush(); err == nil || len(results) != 2 {
+		t.Fatal("incomplete input error expected")
+	}
+
+	// Flush starts a new input
+	results = nil
+	p.Write([]byte(`{"list": ["a", "stop", "b"]}`))
+	if _, err := p.Write([]byte(`{"list": ["c"]}`)); err != nil || len(results) != 2 {
+		t.Fatal("Stop Test failed")
+	}
+
+	if p.Flush() != nil || p.Close() != nil {
+		t.Fatal("Stop must not be an error")
+	}
+
+	if _, err := p.Write([]byte(`{}`)); err == nil {
+		t.Fatal("Write after Close error expected")
+	}
+
+	errFound := errors.New("found")
+	p, _ = NewPushParser(func(json *JSON) error { return errFound }, WithLoopPath("list"))
+
+	if _, err := p.Write([]byte(`{"list": [{"id": 1}]}`)); err != errFound || p.Close() != errFound {
+		t.Fatal("callback error expected")
+	}
+
+	for _, opts := range [][]Option{{WithParallel(2)}, {WithParallel(2), WithUnordered()}, {WithUnordered()}} {
+		if _, err := NewPushParser(func(json *JSON) error { return nil }, opts...); err == nil {
+			t.Fatal("parallel push parser error expected")
+		}
+	}
+
+}
+
+func TestNDJSON(t *testing.T) {
+
+	var buf bytes.Buffer
+	for i := 0; i < 2000; i++ {
+		buf.WriteString(`{"id": ` + strconv.Itoa(i) + `, "name": "line ` + strconv.Itoa(i) + `", "skip": [1, 2]}` + "\n")
+	}
+	input := buf.Bytes()
+
+	var expected []interface{}
+	for _, json := range allResult(NewJSONParser(bufio.NewReader(bytes.NewReader(input)), "").TopLevelValues().SkipProps([]string{"skip"})) {
+		expected = append(expected, plain(json))
+	}
+
+	p := NewNDJSONParser(bytes.NewReader(input), int64(len(input)), 3).ChunkSize(1000).SkipProps([]string{"skip"})
+	var results []interface{}
+	for json := range p.Stream() {
+		if json.Err != nil {
+			t.Fatal(json.Err)
+		}
+		results = append(results, plain(json))
+	}
+
+	if len(results) != 2000 || !reflect.DeepEqual(expected, results) {
+		t.Fatal("ndjson results must equal sequential results")
+	}
+
+	if atomic.LoadUint64(&p.TotalReadSize) != uint64(len(input)) {
+		t.Fatal("invalid TotalReadSize")
+	}
+
+	p = NewNDJSONParser(bytes.NewReader(input), int64(len(input)), 3).ChunkSize(1000).SkipProps([]string{"skip"}).Unordered()
+	results = nil
+	for _, json := range p.Parse() {
+		results = append(results, plain(json))
+	}
+	sort.Slice(results, func(a, b int) bool {
+		return results[a].(map[string]interface{})["name"].(string) < results[b].(map[string]interface{})["name"].(string)
+	})
+	sort.Slice(expected, func(a, b int) bool {
+		return expected[a].(map[string]interface{})["name"].(string) < expected[b].(map[string]interface{})["name"].(string)
+	})
+
+	if !reflect.DeepEqual(expected, results) {
+		t.Fatal("unordered ndjson results must equal sequential results")
+	}
+
+	// invalid line
+	invalid := "{\"id\": 1}\n{\"id\": 2\n{\"id\": 3}\n"
+	results = nil
+	for _, json := range NewNDJSONParser(strings.NewReader(invalid), int64(len(invalid)), 2).ChunkSize(4).Parse() {
+		results = append(results, json)
+	}
+
+	if len(results) != 2 || results[0].(*JSON).Err != nil || results[1].(*JSON).Err == nil {
+		t.Fatal("Invalid error expected")
+	}
+
+	// progress within a chunk
+	p = NewNDJSONParser(bytes.NewReader(input), int64(len(input)), 1)
+	first := <-p.Stream()
+	if read := atomic.LoadUint64(&p.TotalReadSize); read == 0 || read >= uint64(len(input)) || first.Err != nil {
+		t.Fatal("TotalReadSize must advance with the results of a chunk")
+	}
+	for range p.resChan {
+	}
+
+	// no chunks are parsed after an error
+	invalid = "{\"id\": 1\n" + string(input)
+	reader := &offsetReader{ReaderAt: strings.NewReader(invalid)}
+	results = nil
+	for _, json := range NewNDJSONParser(reader, int64(len(invalid)), 2).ChunkSize(1000).Parse() {
+		results = append(results, json)
+	}
+
+	if len(results) != 1 || results[0].(*JSON).Err == nil || reader.max > int64(len(invalid)/2) {
+		t.Fatal("parsing must end after an error", reader.max)
+	}
+
+}
+
+// offsetReader records the largest offset read
+type offsetReader struct {
+	io.ReaderAt
+	mu  sync.Mutex
+	max int64
+}
+
+func (r *offsetReader) ReadAt(b []byte, off int64) (int, error) {
+	r.mu.Lock()
+	r.max = max(r.max, off)
+	r.mu.Unlock()
+	return r.ReaderAt.ReadAt(b, off)
+}
+
+func TestArrayParser(t *testing.T) {
+
+	var buf bytes.Buffer
+	buf.WriteString(" [")
+	for i := 0; i < 500; i++ {
+		if i > 0 {
+			buf.WriteString(",\n")
+		}
+		buf.WriteString(`{"id": ` + strconv.Itoa(i) + `, "text": "a \"quoted\", [bracketed] {text}\\", "path": "c:\\", "nested": [[1, 2], {"x": ",]"}], "skip": [1, 2]}`)
+	}
+	buf.WriteString("] \n")
+	input := buf.Bytes()
+
+	sequential := func(input string) []interface{} {
+		var res []interface{}
+		for _, json := range NewJSONParser(bufio.NewReader(strings.NewReader(input)), "").SkipProps([]string{"skip"}).Parse() {
+			res = append(res, plain(json))
+		}
+		return res
+	}
+
+	expected := sequential(string(input))
+
+	// small ranges begin inside strings, after backslashes and in nested values
+	for _, chunkSize := range []int64{7, 13, 64, 1000, 1 << 20} {
+
+		p := NewArrayParser(bytes.NewReader(input), int64(len(input)), 3).ChunkSize(chunkSize).SkipProps([]string{"skip"})
+		var results []interface{}
+		for json := range p.Stream() {
+			if json.Err != nil {
+				t.Fatal(json.Err)
+			}
+			results = append(results, plain(json))
+		}
+
+		if len(results) != 500 || !reflect.DeepEqual(expected, results) {
+			t.Fatal("array results must equal sequential results", chunkSize)
+		}
+
+		if atomic.LoadUint64(&p.TotalReadSize) != uint64(len(input)) {
+			t.Fatal("invalid TotalReadSize", chunkSize)
+		}
+
+	}
+
+	// like sequential parsing values after the array and empty elements are ignored
+	for _, input := range []string{`[1, [2, 3], "4,5"] [6, 7]`, `[1,, 2,]`, `[]`, ` [ ] `, `["a\\", ["b", "\"]"], "c"]`} {
+
+		for _, chunkSize := range []int64{1, 2, 3, 5} {
+			var results []interface{}
+			for _, json := range NewArrayParser(strings.NewReader(input), int64(len(input)), 2).ChunkSize(chunkSize).Parse() {
+				results = append(results, plain(json))
+			}
+
+			if !reflect.DeepEqual(sequential(input), results) {
+				t.Fatal("array results must equal sequential results", input, chunkSize)
+			}
+		}
+
+	}
+
+	// invalid
+	for _, input := range []string{`{"a": [1, 2]}`, `[1, 2`, `[1, tru, 3]`} {
+		results := NewArrayParser(strings.NewReader(input), int64(len(input)), 2).ChunkSize(2).Parse()
+
+		if len(results) == 0 || results[len(results)-1].Err == nil {
+			t.Fatal("Invalid error expected", input)
+		}
+	}
+	// results are sent while later ranges are still to be scanned
+	large := bytes.Repeat(input[2:len(input)-3], 20)
+	large = append(append([]byte("["), bytes.ReplaceAll(large, []byte("}{"), []byte("},{"))...), ']')
+	reader := &offsetReader{ReaderAt: bytes.NewReader(large)}
+	p := NewArrayParser(reader, int64(len(large)), 2).ChunkSize(64)
+
+	if first := <-p.Stream(); first.Err != nil {
+		t.Fatal(first.Err)
+	}
+
+	reader.mu.Lock()
+	read := reader.max
+	reader.mu.Unlock()
+
+	count := 1
+	for range p.resChan {
+		count++
+	}
+
+	if read > int64(len(large)/2) || count != 10000 {
+		t.Fatal("first result must not wait for the whole input", read, count)
+	}
+
+}
+
 func TestInvalid(t *testing.T) {
 
 	invalidStart := `{{"Name": "Ed", "Text": "Go fmt."},"s":"valid","s2":in"valid"}`
@@ -519,6 +2073,162 @@ func Benchmark2(b *testing.B) {
 	}
 }
 
+func BenchmarkRaw(b *testing.B) {
+
+	for n := 0; n < b.N; n++ {
+		p := getparser("a").Raw()
+		for json := range p.Stream() {
+			nothing(json)
+		}
+	}
+}
+
+// lexInput is a large array of items made of strings, numbers and literals,
+// so that parsing it is dominated by lexing
+var lexInput = func() []byte {
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"items": [`)
+	for i := 0; i < 20000; i++ {
+		if i > 0 {
+			buf.WriteString(",\n")
+		}
+		buf.WriteString(`{"id": ` + strconv.Itoa(i) + `, "name": "item number ` + strconv.Itoa(i) + `", "price": 1024.75, "ratio": -3.25e-8,` +
+			` "active": true, "deleted": false, "parent": null, "text": "` + strings.Repeat("lorem ipsum dolor sit amet ", 4) + `",` +
+			` "escaped": "line\nbreak \"quoted\" tab\t", "tags": ["alpha", "beta", "gamma", 1, 2, 3]}`)
+	}
+	buf.WriteString(`]}`)
+	return buf.Bytes()
+
+}()
+
+func BenchmarkLex(b *testing.B) {
+
+	b.SetBytes(int64(len(lexInput)))
+	for n := 0; n < b.N; n++ {
+		br := bufio.NewReaderSize(bytes.NewReader(lexInput), 65536)
+		for json := range NewJSONParser(br, "items").Stream() {
+			nothing(json)
+		}
+	}
+}
+
+func BenchmarkLexRaw(b *testing.B) {
+
+	b.SetBytes(int64(len(lexInput)))
+	for n := 0; n < b.N; n++ {
+		br := bufio.NewReaderSize(bytes.NewReader(lexInput), 65536)
+		for json := range NewJSONParser(br, "items").Raw().Stream() {
+			nothing(json)
+		}
+	}
+}
+
+// skipInput is a large array whose items mostly consist of skipped subtrees
+var skipInput = func() []byte {
+
+	item := `{"id": 1, "big": {"text": "` + strings.Repeat("lorem ipsum [dolor] {sit} amet ", 64) + `",` +
+		`"blob": "` + strings.Repeat("QUJDREVGR0hJSktMTU5PUFFSU1RVVldYWVo", 128) + `",` +
+		"\n        \"values\": [" + strings.Repeat("\n            1024.5,  -3.25e-8,  true,  null,", 64) + "\n            0\n        ]," +
+		`"list": [` + strings.Repeat(`{"a": [1, 2, 3], "b": "c"}, `, 64) + `null]}}`
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"items": [`)
+	for i := 0; i < 256; i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(item)
+	}
+	buf.WriteString(`]}`)
+	return buf.Bytes()
+
+}()
+
+func BenchmarkSkip(b *testing.B) {
+
+	b.SetBytes(int64(len(skipInput)))
+	for n := 0; n < b.N; n++ {
+		br := bufio.NewReaderSize(bytes.NewReader(skipInput), 65536)
+		p := NewJSONParser(br, "items").SkipProps([]string{"big"})
+		for _, json := range p.Parse() {
+			nothing(json)
+		}
+	}
+}
+
+func BenchmarkPooled(b *testing.B) {
+
+	for n := 0; n < b.N; n++ {
+		p := getparser("a").SkipProps([]string{"a11"}).Pooled()
+		for json := range p.Stream() {
+			nothing(json)
+			json.Release()
+		}
+	}
+}
+
+func BenchmarkTape(b *testing.B) {
+
+	for n := 0; n < b.N; n++ {
+		p := getparser("a").SkipProps([]string{"a11"}).Tape()
+		for json := range p.Stream() {
+			nothing(json)
+		}
+	}
+}
+
+func BenchmarkReset(b *testing.B) {
+
+	body := []byte(`{"list": [{"id": 1, "name": "a"}, {"id": 2, "name": "b"}]}`)
+	pool := sync.Pool{New: func() interface{} { return NewBytesJSONParser(nil, "list") }}
+
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		p := pool.Get().(*JsonParser)
+		for _, json := range p.ResetBytes(body).Parse() {
+			nothing(json)
+		}
+		pool.Put(p)
+	}
+}
+
+func BenchmarkForEach(b *testing.B) {
+
+	for n := 0; n < b.N; n++ {
+		p := getparser("a").SkipProps([]string{"a11"})
+		p.ForEach(func(json *JSON) error {
+			nothing(json)
+			return nil
+		})
+	}
+}
+
+func BenchmarkTokenizer(b *testing.B) {
+
+	b.SetBytes(int64(len(skipInput)))
+	for n := 0; n < b.N; n++ {
+		tk := NewBytesTokenizer(skipInput)
+		for {
+			if _, err := tk.Next(); err != nil {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkParallel(b *testing.B) {
+
+	b.SetBytes(int64(len(skipInput)))
+	for n := 0; n < b.N; n++ {
+		br := bufio.NewReaderSize(bytes.NewReader(skipInput), 65536)
+		p := NewJSONParser(br, "items").Parallel(4)
+		for json := range p.Stream() {
+			nothing(json)
+		}
+	}
+}
+
 func nothing(j *JSON) {
 
 }
@@ -0,0 +1,159 @@
+package jsparser
+
+// lazyContext holds what is needed to materialize a lazy value. It is
+// shared by the lazy values a parser keeps at the same depth.
+type lazyContext struct {
+	skipProps  map[string]bool
+	maxNesting int
+	depth      int // of the parser within the values
+}
+
+// lazyContext returns the context of lazy values at the current depth
+func (j *JsonParser) lazyContext() *lazyContext {
+
+	for len(j.lazyContexts) <= j.depth {
+		j.lazyContexts = append(j.lazyContexts, &lazyContext{
+			skipProps:  j.skipProps,
+			maxNesting: j.maxNesting,
+			depth:      len(j.lazyContexts) + 1,
+		})
+	}
+	return j.lazyContexts[j.depth]
+
+}
+
+// rawNested copies a nested array or object starting with b into a raw result
+func (j *JsonParser) rawNested(b byte, valType ValueType) *JSON {
+
+	if j.isZeroCopy(valType) {
+		return j.mappedRaw(b, valType)
+	}
+
+	j.scratch.reset()
+	j.scratch.add(b)
+
+	err := j.rawArrayOrObject()
+	if err != nil {
+		return &JSON{Err: err, ValueType: Invalid}
+	}
+
+	return j.newRaw(valType, j.scratch.bytes())
+
+}
+
+// nestedValue parses an array or object within a result, or keeps it raw
+// for lazy parsers and below the materialized depth
+func (j *JsonParser) nestedValue(b byte, valType ValueType) *JSON {
+
+	if j.maxDepth > 0 && j.depth+2 > j.maxDepth { // the result itself is level 1
+		return j.rawNested(b, valType)
+	}
+
+	if j.maxNesting > 0 && j.depth+2 > j.maxNesting {
+		return &JSON{Err: errDepth, ValueType: Invalid}
+	}
+
+	if j.isLazy {
+		res := j.rawNested(b, valType)
+		if res.Err == nil {
+			res.lazy = j.lazyContext()
+		}
+		return res
+	}
+
+	j.depth++
+	res := j.newJSON(valType)
+	if valType == Array {
+		j.getArrayTree(res)
+	} else {
+		j.getObjectTree(res)
+	}
+	j.depth--
+	return res
+
+}
+
+// materialize parses the raw bytes of a lazy value into ObjectVals or
+// ArrayVals. Values nested in it stay lazy.
+func (r *JSON) materialize() {
+
+	lc := r.lazy
+	if lc == nil {
+		return
+	}
+
+	j := &JsonParser{
+		skipProps:  lc.skipProps,
+		isLazy:     true,
+		isPooled:   r.spare != nil,
+		depth:      lc.depth,
+		maxNesting: lc.maxNesting,
+		buf:        r.Raw,
+		scratch:    &scratch{data: make([]byte, 64)},
+	}
+
+	j.skipWS() // opening bracket
+
+	if r.ValueType == Object {
+		if r.spare != nil {
+			r.ObjectVals = r.spare.vals
+		}
+		if r.ObjectVals == nil {
+			r.ObjectVals = map[string]interface{}{}
+		}
+		j.getObjectTree(r)
+	} else {
+		if r.spare != nil {
+			r.ArrayVals = r.spare.arr
+		}
+		j.getArrayTree(r)
+	}
+
+	r.lazy = nil
+	if r.spare != nil {
+		r.spare.raw = r.Raw[:0]
+	}
+	r.Raw = nil
+
+}
+
+// Object returns the members of an object. Nested values of lazy parsers are
+// parsed on the first call, or nil is returned and Err set if they are invalid.
+func (r *JSON) Object() map[string]interface{} {
+
+	r.materialize()
+	if r.Err != nil {
+		return nil
+	}
+	return r.ObjectVals
+
+}
+
+// Array returns the elements of an array, parsing a lazy value like Object
+func (r *JSON) Array() []interface{} {
+
+	r.materialize()
+	if r.Err != nil {
+		return nil
+	}
+	return r.ArrayVals
+
+}
+
+// Get returns the member name of an object as stored in ObjectVals, or nil
+func (r *JSON) Get(name string) interface{} {
+
+	return r.Object()[name]
+
+}
+
+// At returns element i of an array as stored in ArrayVals, or nil
+func (r *JSON) At(i int) interface{} {
+
+	vals := r.Array()
+	if i < 0 || i >= len(vals) {
+		return nil
+	}
+	return vals[i]
+
+}
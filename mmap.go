@@ -0,0 +1,133 @@
+package jsparser
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"unsafe"
+)
+
+// NewFileJSONParser returns a parser reading the file at path through a read
+// only memory mapping, without bufio.Reader and without copying unescaped
+// strings and raw values.
+//
+// StringVal, string values in ObjectVals and ArrayVals, and Raw of results
+// may reference the mapping. They must not be used after Close, so copy the
+// ones kept longer, e.g. with strings.Clone. The file must not be modified
+// while mapped.
+func NewFileJSONParser(path string, loopProp string) (*JsonParser, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // the mapping stays valid
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := info.Size()
+	if int64(int(size)) != size {
+		return nil, errors.New("File too large to map")
+	}
+
+	var data []byte
+	if size > 0 {
+		data, err = mmap(f, int(size))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	j := NewJSONParser(nil, loopProp)
+	j.isMapped = true
+	j.mapped = data
+	j.buf = data
+	return j, nil
+
+}
+
+// Close unmaps the file of a parser returned by NewFileJSONParser. It must
+// be called after the results are consumed, as described there.
+func (j *JsonParser) Close() error {
+
+	data := j.mapped
+	j.mapped = nil
+	j.buf = nil
+
+	if data == nil {
+		return nil
+	}
+	return munmap(data)
+
+}
+
+// stringValue returns the rest of a string whose opening quote was read.
+// Strings without escapes reference the input of mapped parsers.
+func (j *JsonParser) stringValue() (string, error) {
+
+	if j.isMapped {
+
+		s := j.buf[j.pos:]
+		i := bytes.IndexByte(s, '"')
+
+		if i >= 0 && !hasStringStop(s[:i]) {
+			j.pos += i + 1
+			if i == 0 {
+				return "", nil
+			}
+			return unsafe.String(&s[0], i), nil
+		}
+
+	}
+
+	err := j.string()
+	if err != nil {
+		return "", err
+	}
+	return j.scratch.string(), nil
+
+}
+
+// hasStringStop reports whether s contains an escape or a control character
+func hasStringStop(s []byte) bool {
+
+	for _, c := range s {
+		if stringStop[c] {
+			return true
+		}
+	}
+	return false
+
+}
+
+// mappedRaw returns a raw result referencing a string, array or object in
+// the input of a mapped parser
+func (j *JsonParser) mappedRaw(b byte, valType ValueType) *JSON {
+
+	start := j.pos - 1
+
+	var err error
+	if valType == String {
+		err = j.skipString()
+	} else {
+		err = j.skipArrayOrObject(b, b+2) // ']' and '}' follow '[' and '{' by 2
+	}
+
+	if err != nil {
+		return &JSON{Err: err, ValueType: Invalid}
+	}
+
+	return &JSON{Raw: j.buf[start:j.pos:j.pos], ValueType: valType}
+
+}
+
+// isZeroCopy reports whether raw values of type valType reference the input.
+// Pooled results reuse their Raw and always get a copy.
+func (j *JsonParser) isZeroCopy(valType ValueType) bool {
+
+	return j.isMapped && !j.isPooled && (valType == String || valType == Array || valType == Object)
+
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package jsparser
+
+import (
+	"io"
+	"os"
+)
+
+// mmap reads the first size bytes of f where memory mapping is not supported
+func mmap(f *os.File, size int) ([]byte, error) {
+	data := make([]byte, size)
+	_, err := io.ReadFull(f, data)
+	return data, err
+}
+
+func munmap(data []byte) error {
+	return nil
+}
@@ -0,0 +1,82 @@
+package jsparser
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// NDJSONParser parses JSON Lines input concurrently. The input is split into
+// newline aligned chunks which are parsed on separate goroutines.
+type NDJSONParser struct {
+	chunkedParser[*NDJSONParser]
+}
+
+// NewNDJSONParser returns a parser of the first size bytes of reader which
+// uses the given number of goroutines
+func NewNDJSONParser(reader io.ReaderAt, size int64, workers int) *NDJSONParser {
+
+	n := &NDJSONParser{}
+	n.setup(n, reader, size, workers, n.split, n.parseChunk)
+	return n
+
+}
+
+// split divides the input into chunks of complete lines
+func (n *NDJSONParser) split(emit func(c *chunk) bool) {
+
+	for start := int64(0); start < n.size; {
+
+		end, err := n.lineEnd(start + n.chunkSize)
+
+		if !emit(&chunk{start: start, end: end, err: err}) || err != nil {
+			return
+		}
+		start = end
+
+	}
+
+}
+
+// lineEnd returns the offset following the first newline at or after off
+func (n *NDJSONParser) lineEnd(off int64) (int64, error) {
+
+	buf := make([]byte, 4096)
+
+	for off < n.size {
+
+		if rest := n.size - off; rest < int64(len(buf)) {
+			buf = buf[:rest]
+		}
+
+		m, err := n.reader.ReadAt(buf, off)
+
+		if i := bytes.IndexByte(buf[:m], '\n'); i >= 0 {
+			return off + int64(i) + 1, nil
+		}
+
+		off += int64(m)
+
+		if err == io.EOF || (err == nil && m == 0) {
+			break
+		}
+
+		if err != nil {
+			return off, err
+		}
+
+	}
+
+	return n.size, nil
+
+}
+
+// parseChunk sends the values of a chunk
+func (n *NDJSONParser) parseChunk(c *chunk) {
+
+	section := io.NewSectionReader(n.reader, c.start, c.end-c.start)
+	p := NewJSONParser(bufio.NewReaderSize(section, 65536), "").TopLevelValues().SkipProps(n.skipProps)
+
+	n.sendChunk(p, c)
+
+}
@@ -0,0 +1,248 @@
+package jsparser
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+var (
+	errDepth = errors.New("Value exceeds the depth limit")
+	errSize  = errors.New("Value exceeds the size limit")
+)
+
+// Option configures a parser created by NewParser
+type Option func(j *JsonParser) error
+
+// Limits bound the values a parser accepts. Zero values mean no limit.
+type Limits struct {
+	MaxDepth     int // nesting of arrays and objects built within a result, the result itself being level 1
+	MaxValueSize int // bytes of input of a result, checked while it is read
+}
+
+// overLimit reports whether the value being parsed would extend to index i
+// of buf beyond the size limit
+func (j *JsonParser) overLimit(i int) bool {
+	return j.limit > 0 && j.base+uint64(i) > j.limit
+}
+
+// NewParser returns a parser reading from reader configured by opts. Readers
+// other than a *bufio.Reader are wrapped in one, see WithReadBuffer. Options
+// are validated here, so that an invalid or conflicting configuration is
+// reported as an error instead of surfacing while parsing.
+func NewParser(reader io.Reader, opts ...Option) (*JsonParser, error) {
+
+	j := NewJSONParser(nil, "")
+
+	for _, opt := range opts {
+		if err := opt(j); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := j.validate(); err != nil {
+		return nil, err
+	}
+
+	if br, ok := reader.(*bufio.Reader); ok {
+		j.reader = br
+	} else if reader != nil && j.readSize > 0 {
+		j.reader = bufio.NewReaderSize(reader, j.readSize)
+	} else if reader != nil {
+		j.reader = bufio.NewReader(reader)
+	}
+
+	return j, nil
+
+}
+
+// validate reports options which conflict with each other
+func (j *JsonParser) validate() error {
+
+	switch {
+	case j.isTopLevel && len(j.loopProp) > 0:
+		return errors.New("Top level values require an empty loop path")
+	case j.isSeq && len(j.loopProp) > 0:
+		return errors.New("JSON text sequences require an empty loop path")
+	case j.isSeq && (j.isTopLevel || j.isMembers):
+		return errors.New("JSON text sequences can not be top level values or object members")
+	case j.isMembers && j.isTopLevel:
+		return errors.New("Object members can not be top level values")
+	case j.isUnordered && j.workers == 0:
+		return errors.New("Unordered requires parallel workers")
+	case j.isRaw && (j.isTape || j.isLazy || j.maxDepth > 0):
+		return errors.New("Raw results can not be tapes, lazy or depth limited")
+	case j.isTape && (j.isLazy || j.maxDepth > 0):
+		return errors.New("Tape results can not be lazy or depth limited")
+	case j.isLazy && j.maxDepth > 0:
+		return errors.New("Lazy results can not be depth limited")
+	}
+	return nil
+
+}
+
+// WithLoopPath sets the property whose values are streamed, like the loop
+// property of NewJSONParser. The default "" streams the top level array.
+func WithLoopPath(prop string) Option {
+	return func(j *JsonParser) error {
+		j.loopProp = []byte(prop)
+		return nil
+	}
+}
+
+// WithSkip skips the given properties, like SkipProps
+func WithSkip(props ...string) Option {
+	return func(j *JsonParser) error {
+		j.SkipProps(props)
+		return nil
+	}
+}
+
+// WithChannelBuffer sets the capacity of the channel returned by Stream, 256 by default
+func WithChannelBuffer(size int) Option {
+	return func(j *JsonParser) error {
+		if size < 0 {
+			return errors.New("Channel buffer must not be negative")
+		}
+		j.chanSize = size
+		return nil
+	}
+}
+
+// WithScratchSize sets the initial size of the buffer holding strings and
+// numbers while they are parsed, 2048 bytes by default. It grows as needed.
+func WithScratchSize(size int) Option {
+	return func(j *JsonParser) error {
+		if size < 1 {
+			return errors.New("Scratch size must be positive")
+		}
+		j.scratch.data = make([]byte, size)
+		return nil
+	}
+}
+
+// WithReadBuffer sets the size of the bufio.Reader wrapping readers which
+// are not one already, 4096 bytes by default
+func WithReadBuffer(size int) Option {
+	return func(j *JsonParser) error {
+		if size < 1 {
+			return errors.New("Read buffer must be positive")
+		}
+		j.readSize = size
+		return nil
+	}
+}
+
+// WithLimits makes the parser end with an error when a result exceeds limits
+func WithLimits(limits Limits) Option {
+	return func(j *JsonParser) error {
+		if limits.MaxDepth < 0 || limits.MaxValueSize < 0 {
+			return errors.New("Limits must not be negative")
+		}
+		j.maxNesting = limits.MaxDepth
+		j.maxValueSize = limits.MaxValueSize
+		return nil
+	}
+}
+
+// WithTopLevelValues streams every top level value, like TopLevelValues
+func WithTopLevelValues() Option {
+	return func(j *JsonParser) error {
+		j.TopLevelValues()
+		return nil
+	}
+}
+
+// WithJSONSeq reads JSON text sequences, like JSONSeq
+func WithJSONSeq() Option {
+	return func(j *JsonParser) error {
+		j.JSONSeq()
+		return nil
+	}
+}
+
+// WithObjectMembers streams object members, like ObjectMembers
+func WithObjectMembers() Option {
+	return func(j *JsonParser) error {
+		j.ObjectMembers()
+		return nil
+	}
+}
+
+// WithPositions fills the positions of results, like Positions
+func WithPositions() Option {
+	return func(j *JsonParser) error {
+		j.Positions()
+		return nil
+	}
+}
+
+// WithRaw sends unparsed results, like Raw
+func WithRaw() Option {
+	return func(j *JsonParser) error {
+		j.Raw()
+		return nil
+	}
+}
+
+// WithCaptureProps captures properties of enclosing objects, like CaptureProps
+func WithCaptureProps(props ...string) Option {
+	return func(j *JsonParser) error {
+		j.CaptureProps(props)
+		return nil
+	}
+}
+
+// WithPooled takes results from a pool, like Pooled
+func WithPooled() Option {
+	return func(j *JsonParser) error {
+		j.Pooled()
+		return nil
+	}
+}
+
+// WithParallel decodes results on worker goroutines, like Parallel
+func WithParallel(workers int) Option {
+	return func(j *JsonParser) error {
+		if workers < 0 {
+			return errors.New("Workers must not be negative")
+		}
+		j.Parallel(workers)
+		return nil
+	}
+}
+
+// WithUnordered sends parallel results as soon as they are decoded, like Unordered
+func WithUnordered() Option {
+	return func(j *JsonParser) error {
+		j.Unordered()
+		return nil
+	}
+}
+
+// WithLazy parses nested values on first access, like Lazy
+func WithLazy() Option {
+	return func(j *JsonParser) error {
+		j.Lazy()
+		return nil
+	}
+}
+
+// WithMaterializeDepth builds only the first levels of results, like MaterializeDepth
+func WithMaterializeDepth(levels int) Option {
+	return func(j *JsonParser) error {
+		if levels < 0 {
+			return errors.New("Materialize depth must not be negative")
+		}
+		j.MaterializeDepth(levels)
+		return nil
+	}
+}
+
+// WithTape sends results as tapes, like Tape
+func WithTape() Option {
+	return func(j *JsonParser) error {
+		j.Tape()
+		return nil
+	}
+}
@@ -0,0 +1,155 @@
+package jsparser
+
+import "sync"
+
+// job is a raw result passed to the decoding workers of a parallel parser
+type job struct {
+	seq  uint64
+	res  *JSON
+	size uint64 // input bytes of res
+}
+
+// startWorkers starts the decoding workers. Their results are delivered by
+// collect, which runs on its own goroutine or, for ForEach, on the calling one.
+func (j *JsonParser) startWorkers() {
+
+	j.jobs = make(chan job, j.workers*4)
+	j.decoded = make(chan job, j.workers*4)
+	j.inFlight = make(chan struct{}, j.workers*64) // bounds results waiting to be reordered
+	j.collected = make(chan struct{})
+	j.seq = 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < j.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			j.decodeJobs()
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(j.decoded)
+	}()
+
+}
+
+// parseCollecting parses on another goroutine while decoded results are
+// delivered on the calling goroutine
+func (j *JsonParser) parseCollecting() {
+
+	j.startWorkers()
+
+	parsed := make(chan struct{})
+	go func() {
+		defer close(parsed)
+		j.parse()
+	}()
+
+	j.collect()
+	<-parsed
+
+}
+
+// stopWorkers waits until every dispatched result is delivered
+func (j *JsonParser) stopWorkers() {
+
+	close(j.jobs)
+	<-j.collected
+
+}
+
+// dispatch passes a raw result to the decoding workers
+func (j *JsonParser) dispatch(res *JSON, size uint64) {
+
+	j.inFlight <- struct{}{}
+	j.jobs <- job{seq: j.seq, res: res, size: size}
+	j.seq++
+
+}
+
+// decodeJobs decodes raw results until the jobs channel is closed
+func (j *JsonParser) decodeJobs() {
+
+	w := &JsonParser{
+		skipProps:  j.skipProps,
+		isPooled:   j.isPooled,
+		isTape:     j.isTape,
+		isLazy:     j.isLazy,
+		maxDepth:   j.maxDepth,
+		maxNesting: j.maxNesting,
+		isMapped:   j.isMapped && !j.isPooled, // raw copies of pooled results are recycled
+		scratch:    &scratch{data: make([]byte, 2048)},
+	}
+
+	for jb := range j.jobs {
+		if jb.res.Err == nil && jb.res.Raw != nil && !j.isRaw {
+			jb.res = w.decode(jb.res)
+		}
+		j.decoded <- jb
+	}
+
+}
+
+// decode parses the value of a raw result, keeping its key, context and position
+func (j *JsonParser) decode(raw *JSON) *JSON {
+
+	j.buf = raw.Raw
+	j.pos = 0
+	j.base = 0
+
+	b, _ := j.skipWS()
+	res := j.rootValue(b)
+
+	res.Key = raw.Key
+	res.Context = raw.Context
+	res.Path = raw.Path
+	res.Index = raw.Index
+	res.Start = raw.Start
+	res.End = raw.End
+
+	j.buf = nil
+	raw.Release()
+	return res
+
+}
+
+// collect delivers decoded results, in input order unless unordered. Like
+// a sequential parser nothing follows an error in ordered mode.
+func (j *JsonParser) collect() {
+
+	defer close(j.collected)
+
+	pending := map[uint64]job{}
+	next := uint64(0)
+	failed := false
+
+	for jb := range j.decoded {
+
+		if j.isUnordered {
+			<-j.inFlight
+			j.deliver(jb.res, jb.size)
+			continue
+		}
+
+		pending[jb.seq] = jb
+
+		for {
+			jb, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			<-j.inFlight
+
+			if !failed {
+				j.deliver(jb.res, jb.size)
+				failed = jb.res.Err != nil
+			}
+		}
+
+	}
+
+}
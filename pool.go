@@ -0,0 +1,103 @@
+package jsparser
+
+import "sync"
+
+// results of pooled parsers are recycled through jsonPool
+var jsonPool = sync.Pool{
+	New: func() interface{} { return &JSON{spare: &spare{}} },
+}
+
+// spare keeps the map and slices of a pooled result for reuse, outside of
+// JSON so that results of other parsers don't carry them
+type spare struct {
+	vals map[string]interface{}
+	arr  []interface{}
+	raw  []byte
+}
+
+// newJSON returns an empty result of the given type, taken from jsonPool
+// when the parser is pooled
+func (j *JsonParser) newJSON(valueType ValueType) *JSON {
+
+	if !j.isPooled {
+		if valueType == Object {
+			return &JSON{ObjectVals: map[string]interface{}{}, ValueType: Object}
+		}
+		return &JSON{ValueType: valueType}
+	}
+
+	res := jsonPool.Get().(*JSON)
+	res.ValueType = valueType
+
+	switch valueType {
+	case Object:
+		if res.spare.vals == nil {
+			res.spare.vals = map[string]interface{}{}
+		}
+		res.ObjectVals = res.spare.vals
+	case Array:
+		res.ArrayVals = res.spare.arr
+	}
+
+	return res
+
+}
+
+// newRaw returns a result holding a copy of raw, taken from jsonPool when
+// the parser is pooled
+func (j *JsonParser) newRaw(valueType ValueType, raw []byte) *JSON {
+
+	if !j.isPooled {
+		return &JSON{Raw: append([]byte(nil), raw...), ValueType: valueType}
+	}
+
+	res := jsonPool.Get().(*JSON)
+	res.ValueType = valueType
+	res.Raw = append(res.spare.raw, raw...)
+	return res
+
+}
+
+// Release returns a result of a pooled parser together with its nested
+// values to the pool, keeping their maps and slices for reuse. Neither the
+// result nor anything obtained from its ObjectVals, ArrayVals or Raw may be
+// used afterwards. Release does nothing for results of other parsers.
+func (r *JSON) Release() {
+
+	if r == nil || r.spare == nil {
+		return
+	}
+
+	vals := r.ObjectVals
+	if vals == nil {
+		vals = r.spare.vals
+	}
+	for k, v := range vals {
+		if n, ok := v.(*JSON); ok {
+			n.Release()
+		}
+		delete(vals, k)
+	}
+
+	arr := r.ArrayVals
+	if arr == nil {
+		arr = r.spare.arr
+	}
+	for i, v := range arr {
+		if n, ok := v.(*JSON); ok {
+			n.Release()
+		}
+		arr[i] = nil
+	}
+
+	raw := r.Raw
+	if raw == nil {
+		raw = r.spare.raw
+	}
+
+	sp := r.spare
+	sp.vals, sp.arr, sp.raw = vals, arr[:0], raw[:0]
+	*r = JSON{spare: sp}
+	jsonPool.Put(r)
+
+}
@@ -0,0 +1,171 @@
+package jsparser
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// PushParser parses input passed to Write in chunks of any size, for inputs
+// which arrive through callbacks instead of a reader. Results are passed to
+// a function as soon as they are complete, while Write is running.
+//
+// The parser runs on its own goroutine, reading the chunks through a
+// reader. Write hands a chunk over and returns once the parser has consumed
+// all of it and waits for more, so the caller and the parser never run at
+// the same time.
+type PushParser struct {
+	j        *JsonParser
+	fn       func(*JSON) error
+	reader   *chunkReader
+	finished chan struct{} // closed when parsing ended
+	err      error         // of parsing, read after finished is closed
+	closed   bool
+}
+
+// chunkReader reads the chunks passed to Write
+type chunkReader struct {
+	chunks  chan []byte
+	more    chan struct{} // the previous chunk is consumed
+	chunk   []byte
+	started bool
+}
+
+// NewPushParser returns a push parser configured by opts like NewParser,
+// which calls fn with every result. The first error, whether of parsing or
+// returned by fn, ends parsing and is returned by Write, Flush or Close.
+// fn may return Stop to end parsing without an error. Parallel decoding is
+// rejected, as results could be passed to fn after Write returned.
+func NewPushParser(fn func(*JSON) error, opts ...Option) (*PushParser, error) {
+
+	j, err := NewParser(nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if j.workers > 0 {
+		return nil, errors.New("Push parsers can not be parallel")
+	}
+
+	p := &PushParser{j: j, fn: fn}
+	p.start()
+	return p, nil
+
+}
+
+// start parses a new input on a new goroutine
+func (p *PushParser) start() {
+
+	p.reader = &chunkReader{chunks: make(chan []byte), more: make(chan struct{})}
+	p.finished = make(chan struct{})
+	p.err = nil
+	p.j.Reset(bufio.NewReader(p.reader))
+
+	go func() {
+		defer close(p.finished)
+		p.err = p.j.ForEach(p.visit)
+	}()
+
+}
+
+// visit passes valid results to fn and ends parsing with the first error
+func (p *PushParser) visit(res *JSON) error {
+
+	if res.Err != nil {
+		return res.Err
+	}
+	return p.fn(res)
+
+}
+
+// Write passes the next chunk of the input to the parser and returns after
+// the results completed by it were passed to fn. Once parsing ended, e.g.
+// after the end of the loop array, further input is ignored.
+func (p *PushParser) Write(chunk []byte) (int, error) {
+
+	if p.closed {
+		return 0, errors.New("Write after Close")
+	}
+
+	if len(chunk) == 0 {
+		return 0, nil
+	}
+
+	select {
+	case p.reader.chunks <- chunk:
+	case <-p.finished:
+		return len(chunk), p.err
+	}
+
+	select {
+	case <-p.reader.more:
+		return len(chunk), nil
+	case <-p.finished:
+	}
+
+	if p.err != nil {
+		return len(chunk) - len(p.reader.chunk), p.err
+	}
+	return len(chunk), nil
+
+}
+
+// Flush ends the input, reports an error if it was incomplete or invalid,
+// and makes the parser ready for a new input, keeping its configuration.
+func (p *PushParser) Flush() error {
+
+	if p.closed {
+		return errors.New("Flush after Close")
+	}
+
+	err := p.finish()
+	p.start()
+	return err
+
+}
+
+// Close ends the input like Flush and stops the goroutine of the parser. It
+// must be called when the parser is no longer used.
+func (p *PushParser) Close() error {
+
+	if p.closed {
+		return nil
+	}
+
+	err := p.finish()
+	p.closed = true
+	return err
+
+}
+
+// finish signals the end of the input and waits until parsing ended
+func (p *PushParser) finish() error {
+
+	close(p.reader.chunks)
+	<-p.finished
+	return p.err
+
+}
+
+func (r *chunkReader) Read(b []byte) (int, error) {
+
+	if len(r.chunk) == 0 {
+
+		if r.started {
+			r.more <- struct{}{}
+		}
+		r.started = true
+
+		chunk, ok := <-r.chunks
+		if !ok {
+			return 0, io.EOF
+		}
+		r.chunk = chunk
+
+	}
+
+	n := copy(b, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+
+}
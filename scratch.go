@@ -37,6 +37,24 @@ func (s *scratch) add(c byte) {
 	s.fill++
 }
 
+// append string to scratch buffer
+func (s *scratch) addString(str string) {
+	for s.fill+len(str) >= cap(s.data) {
+		s.grow()
+	}
+
+	s.fill += copy(s.data[s.fill:], str)
+}
+
+// append bytes to scratch buffer
+func (s *scratch) addBytes(b []byte) {
+	for s.fill+len(b) >= cap(s.data) {
+		s.grow()
+	}
+
+	s.fill += copy(s.data[s.fill:], b)
+}
+
 // append encoded rune to scratch buffer
 func (s *scratch) addRune(r rune) int {
 	if s.fill+utf8.UTFMax >= cap(s.data) {
@@ -48,9 +66,9 @@ func (s *scratch) addRune(r rune) int {
 	return n
 }
 
-// grow result buffer
+// grow result buffer, which is allocated on the first result
 func (s *scratch) growRes() {
-	ndata := make([]*JSON, cap(s.dataRes)*2)
+	ndata := make([]*JSON, max(cap(s.dataRes)*2, 16))
 	copy(ndata, s.dataRes[:])
 	s.dataRes = ndata
 }
@@ -65,6 +83,12 @@ func (s *scratch) addRes(res *JSON) {
 	s.fillRes++
 }
 
+// resetRes drops the results, which belong to the caller of Parse
+func (s *scratch) resetRes() {
+	s.dataRes = nil
+	s.fillRes = 0
+}
+
 func (s *scratch) allRes() []*JSON {
 	return s.dataRes[0:s.fillRes]
 }
@@ -0,0 +1,59 @@
+package jsparser
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// word at a time (SWAR) search of structural bytes, used for skipping values.
+// see https://graphics.stanford.edu/~seander/bithacks.html#ValueInWord
+
+const (
+	swarLo    = 0x0101010101010101
+	swarHi    = 0x8080808080808080
+	swarLow7  = 0x7F7F7F7F7F7F7F7F
+	swarQuote = swarLo * '"'
+)
+
+// swarEq sets the high bit of every byte of w equal to the byte broadcast in pattern
+func swarEq(w uint64, pattern uint64) uint64 {
+	v := w ^ pattern
+	return ^((v&swarLow7 + swarLow7) | v | swarLow7)
+}
+
+// swarWord loads the 8 bytes of s starting at i
+func swarWord(s []byte, i int) uint64 {
+	return binary.LittleEndian.Uint64(s[i:])
+}
+
+// swarIndex returns the index of the byte marked by the lowest bit of m
+func swarIndex(m uint64) int {
+	return bits.TrailingZeros64(m) >> 3
+}
+
+// indexStructural returns the index of the first quote or bracket in s, or
+// len(s) if none
+func indexStructural(s []byte) int {
+	const (
+		open  = swarLo * '['
+		close = swarLo * ']'
+	)
+
+	i := 0
+	for ; i+8 <= len(s); i += 8 {
+		w := swarWord(s, i)
+		// '{' and '}' differ from '[' and ']' only in bit 0x20
+		wb := w &^ (swarLo * 0x20)
+		if m := swarEq(w, swarQuote) | swarEq(wb, open) | swarEq(wb, close); m != 0 {
+			return i + swarIndex(m)
+		}
+	}
+
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '"', '[', ']', '{', '}':
+			return i
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,458 @@
+package jsparser
+
+import (
+	"encoding/binary"
+)
+
+// Tape is a compact representation of a parsed value: a flat list of tokens
+// and an arena holding the text of strings, property names and numbers.
+// Tokens are 64 bit words with the token kind in the top byte and a payload
+// in the rest. The payload of a string or number is the offset of its text
+// in the arena, prefixed by a 32 bit length. The payload of an opening
+// bracket is the index of the token following the matching closing bracket,
+// and the payload of a closing bracket is the index of the opening one.
+// Object members are a string token for the name followed by the value.
+type Tape struct {
+	tokens []uint64
+	arena  []byte
+}
+
+// tape token kinds
+const (
+	tapeNull        = 'n'
+	tapeTrue        = 't'
+	tapeFalse       = 'f'
+	tapeString      = '"'
+	tapeNumber      = '0'
+	tapeStartArray  = '['
+	tapeEndArray    = ']'
+	tapeStartObject = '{'
+	tapeEndObject   = '}'
+)
+
+const tapePayload = 1<<56 - 1
+
+func (t *Tape) reset() {
+	t.tokens = t.tokens[:0]
+	t.arena = t.arena[:0]
+}
+
+func (t *Tape) add(kind byte, payload int) {
+	t.tokens = append(t.tokens, uint64(kind)<<56|uint64(payload))
+}
+
+// addText adds a string or number token with its text
+func (t *Tape) addText(kind byte, text []byte) {
+	t.add(kind, len(t.arena))
+	t.arena = binary.LittleEndian.AppendUint32(t.arena, uint32(len(text)))
+	t.arena = append(t.arena, text...)
+}
+
+// open adds an opening bracket and returns its index
+func (t *Tape) open(kind byte) int {
+	t.add(kind, 0)
+	return len(t.tokens) - 1
+}
+
+// close adds the closing bracket of the one at index start
+func (t *Tape) close(kind byte, start int) {
+	t.add(kind, start)
+	t.tokens[start] |= uint64(len(t.tokens))
+}
+
+// clone returns a copy of the tape without spare capacity
+func (t *Tape) clone() *Tape {
+	return &Tape{
+		tokens: append([]uint64(nil), t.tokens...),
+		arena:  append([]byte(nil), t.arena...),
+	}
+}
+
+func (t *Tape) kind(i int) byte {
+	return byte(t.tokens[i] >> 56)
+}
+
+func (t *Tape) payload(i int) int {
+	return int(t.tokens[i] & tapePayload)
+}
+
+func (t *Tape) text(i int) []byte {
+	off := t.payload(i)
+	n := int(binary.LittleEndian.Uint32(t.arena[off:]))
+	return t.arena[off+4 : off+4+n]
+}
+
+// Size returns the number of bytes held by the tape
+func (t *Tape) Size() int {
+	return len(t.tokens)*8 + len(t.arena)
+}
+
+// Root returns a cursor at the value of the tape
+func (t *Tape) Root() Cursor {
+	return Cursor{tape: t, key: -1}
+}
+
+// JSON converts the tape to a JSON tree as built by a parser without Tape
+func (t *Tape) JSON() *JSON {
+	return t.Root().JSON()
+}
+
+// Cursor is a position of a value in a Tape. Cursors are values and moving
+// one returns a new cursor.
+type Cursor struct {
+	tape *Tape
+	i    int // index of the value token
+	key  int // index of the name token of an object member, or -1
+}
+
+// Type returns the type of the value at the cursor
+func (c Cursor) Type() ValueType {
+
+	switch c.tape.kind(c.i) {
+	case tapeString:
+		return String
+	case tapeNumber:
+		return Number
+	case tapeTrue, tapeFalse:
+		return Boolean
+	case tapeNull:
+		return Null
+	case tapeStartArray:
+		return Array
+	case tapeStartObject:
+		return Object
+	}
+	return Invalid
+
+}
+
+// String returns the value of a string or the text of a number, like
+// StringVal. It returns "" for other types.
+func (c Cursor) String() string {
+
+	if k := c.tape.kind(c.i); k != tapeString && k != tapeNumber {
+		return ""
+	}
+	return string(c.tape.text(c.i))
+
+}
+
+// Bool returns the value of a boolean
+func (c Cursor) Bool() bool {
+	return c.tape.kind(c.i) == tapeTrue
+}
+
+// Key returns the member name of a value within an object, or ""
+func (c Cursor) Key() string {
+
+	if c.key < 0 {
+		return ""
+	}
+	return string(c.tape.text(c.key))
+
+}
+
+// Child returns a cursor at the first element of an array or the first
+// member value of an object. It returns false for other types and empty ones.
+func (c Cursor) Child() (Cursor, bool) {
+
+	switch c.tape.kind(c.i) {
+	case tapeStartArray:
+		return c.at(c.i+1, -1)
+	case tapeStartObject:
+		return c.at(c.i+2, c.i+1)
+	}
+	return Cursor{}, false
+
+}
+
+// Next returns a cursor at the following element of the enclosing array or
+// member of the enclosing object. It returns false after the last one.
+func (c Cursor) Next() (Cursor, bool) {
+
+	next := c.i + 1
+	if k := c.tape.kind(c.i); k == tapeStartArray || k == tapeStartObject {
+		next = c.tape.payload(c.i)
+	}
+
+	if c.key < 0 {
+		return c.at(next, -1)
+	}
+	return c.at(next+1, next)
+
+}
+
+// at returns a cursor at index i, following the name token at index key of
+// an object member, unless either is the end of a container
+func (c Cursor) at(i int, key int) (Cursor, bool) {
+
+	if key >= 0 && c.tape.kind(key) == tapeEndObject {
+		return Cursor{}, false
+	}
+
+	if i >= len(c.tape.tokens) {
+		return Cursor{}, false
+	}
+
+	if k := c.tape.kind(i); k == tapeEndArray || k == tapeEndObject {
+		return Cursor{}, false
+	}
+
+	return Cursor{tape: c.tape, i: i, key: key}, true
+
+}
+
+// Get returns a cursor at the value of the member name of an object
+func (c Cursor) Get(name string) (Cursor, bool) {
+
+	if c.tape.kind(c.i) != tapeStartObject {
+		return Cursor{}, false
+	}
+
+	for m, ok := c.Child(); ok; m, ok = m.Next() {
+		if string(c.tape.text(m.key)) == name {
+			return m, true
+		}
+	}
+	return Cursor{}, false
+
+}
+
+// Index returns a cursor at element i of an array
+func (c Cursor) Index(i int) (Cursor, bool) {
+
+	if c.tape.kind(c.i) != tapeStartArray || i < 0 {
+		return Cursor{}, false
+	}
+
+	e, ok := c.Child()
+	for ; ok && i > 0; i-- {
+		e, ok = e.Next()
+	}
+	return e, ok
+
+}
+
+// JSON converts the value at the cursor to a JSON tree
+func (c Cursor) JSON() *JSON {
+
+	switch c.Type() {
+	case String, Number:
+		return &JSON{StringVal: c.String(), ValueType: c.Type()}
+	case Boolean:
+		return &JSON{BoolVal: c.Bool(), ValueType: Boolean}
+	case Null:
+		return &JSON{ValueType: Null}
+	case Array:
+		res := &JSON{ValueType: Array}
+		for e, ok := c.Child(); ok; e, ok = e.Next() {
+			res.ArrayVals = append(res.ArrayVals, e.treeVal())
+		}
+		return res
+	}
+
+	res := &JSON{ObjectVals: map[string]interface{}{}, ValueType: Object}
+	for m, ok := c.Child(); ok; m, ok = m.Next() {
+		res.ObjectVals[m.Key()] = m.treeVal()
+	}
+	return res
+
+}
+
+// treeVal returns the value at the cursor as stored in ArrayVals and ObjectVals
+func (c Cursor) treeVal() interface{} {
+
+	switch c.Type() {
+	case String, Number:
+		return c.String()
+	case Boolean:
+		return c.Bool()
+	case Null:
+		return ""
+	}
+	return c.JSON()
+
+}
+
+// tapeValue parses a single value of type valType starting with b into a tape
+func (j *JsonParser) tapeValue(b byte, valType ValueType) *JSON {
+
+	if j.tape == nil {
+		j.tape = &Tape{}
+	}
+	j.tape.reset()
+
+	err := j.tapeAppend(b, valType)
+	if err != nil {
+		return &JSON{Err: err, ValueType: Invalid}
+	}
+
+	return &JSON{ValueType: valType, Tape: j.tape.clone()}
+
+}
+
+// tapeAppend adds the tokens of a value of type valType starting with b
+func (j *JsonParser) tapeAppend(b byte, valType ValueType) error {
+
+	var err error
+
+	switch valType {
+	case String:
+
+		err = j.string()
+		if err == nil {
+			j.tape.addText(tapeString, j.scratch.bytes())
+		}
+
+	case Number:
+
+		err = j.number(b)
+		if err == nil {
+			j.tape.addText(tapeNumber, j.scratch.bytes())
+		}
+
+	case Boolean:
+
+		var bl bool
+		bl, err = j.boolean()
+		if bl {
+			j.tape.add(tapeTrue, 0)
+		} else {
+			j.tape.add(tapeFalse, 0)
+		}
+
+	case Null:
+
+		err = j.null()
+		j.tape.add(tapeNull, 0)
+
+	case Array, Object:
+
+		if j.maxNesting > 0 && j.depth >= j.maxNesting {
+			return errDepth
+		}
+
+		j.depth++
+		if valType == Array {
+			err = j.tapeArray()
+		} else {
+			err = j.tapeObject()
+		}
+		j.depth--
+
+	}
+
+	return err
+
+}
+
+func (j *JsonParser) tapeArray() error {
+
+	start := j.tape.open(tapeStartArray)
+
+	for {
+
+		b, err := j.skipWS()
+		if err != nil {
+			return j.defaultError()
+		}
+
+		if j.overLimit(j.pos) {
+			return errSize
+		}
+
+		if b == ',' {
+			continue
+		}
+
+		if b == ']' {
+			j.tape.close(tapeEndArray, start)
+			return nil
+		}
+
+		valType, err := j.getValueType(b)
+		if err != nil {
+			return err
+		}
+
+		err = j.tapeAppend(b, valType)
+		if err != nil {
+			return err
+		}
+
+	}
+
+}
+
+func (j *JsonParser) tapeObject() error {
+
+	start := j.tape.open(tapeStartObject)
+
+	for {
+
+		b, err := j.skipWS()
+		if err != nil {
+			return j.defaultError()
+		}
+
+		if j.overLimit(j.pos) {
+			return errSize
+		}
+
+		if b == ',' {
+			continue
+		}
+
+		if b == '}' {
+			j.tape.close(tapeEndObject, start)
+			return nil
+		}
+
+		if b != '"' {
+			return j.defaultError()
+		}
+
+		_, err = j.getPropName()
+		if err != nil {
+			return err
+		}
+
+		skip := j.skipProps[string(j.scratch.bytes())]
+		if !skip {
+			j.tape.addText(tapeString, j.scratch.bytes())
+		}
+
+		b, err = j.skipWS()
+		if err != nil {
+			return j.defaultError()
+		}
+
+		valType, err := j.getValueType(b)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case !skip:
+			err = j.tapeAppend(b, valType)
+		case valType == String:
+			err = j.skipString()
+		case valType == Array:
+			err = j.skipArrayOrObject('[', ']')
+		case valType == Object:
+			err = j.skipArrayOrObject('{', '}')
+		case valType == Number:
+			err = j.number(b)
+		case valType == Boolean:
+			_, err = j.boolean()
+		default:
+			err = j.null()
+		}
+
+		if err != nil {
+			return err
+		}
+
+	}
+
+}
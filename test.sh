@@ -1,7 +1,7 @@
 #!/bin/sh
 
-go test jsparser.go scratch.go jsparser_test.go -v
+go test jsparser.go scratch.go swar.go pool.go parallel.go ndjson.go chunk.go array.go tape.go lazy.go mmap.go mmap_linux.go options.go batch.go foreach.go tokenizer.go push.go jsparser_test.go -v
 
-go test jsparser.go scratch.go jsparser_test.go -v --minify
+go test jsparser.go scratch.go swar.go pool.go parallel.go ndjson.go chunk.go array.go tape.go lazy.go mmap.go mmap_linux.go options.go batch.go foreach.go tokenizer.go push.go jsparser_test.go -v --minify
 
-go test jsparser.go scratch.go jsparser_test.go -v --parseall
\ No newline at end of file
+go test jsparser.go scratch.go swar.go pool.go parallel.go ndjson.go chunk.go array.go tape.go lazy.go mmap.go mmap_linux.go options.go batch.go foreach.go tokenizer.go push.go jsparser_test.go -v --parseall
\ No newline at end of file
@@ -0,0 +1,282 @@
+package jsparser
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// TokenKind is the kind of a Token
+type TokenKind int8
+
+// Token kinds
+const (
+	TokenStartObject TokenKind = iota + 1
+	TokenKey
+	TokenString
+	TokenNumber
+	TokenBool
+	TokenNull
+	TokenEndObject
+	TokenStartArray
+	TokenEndArray
+)
+
+// Token is a single token of the input
+type Token struct {
+	Kind   TokenKind
+	Value  string // member name, string value or number text
+	Bool   bool
+	Depth  int    // enclosing arrays and objects, 0 for top level values and their brackets
+	Offset uint64 // offset of the first byte of the token in the input
+}
+
+// Tokenizer reads the input as a stream of tokens, e.g. for processing
+// custom structures without building trees. The input may hold several top
+// level values like with TopLevelValues. Separators and the grammar of
+// numbers are checked, so that the tokens always form valid JSON.
+type Tokenizer struct {
+	j          *JsonParser
+	stack      []byte // '{' or '[' of every enclosing object and array
+	needComma  bool   // a value of the innermost array or object was read
+	afterComma bool
+	needValue  bool // a member name was read
+	err        error
+}
+
+var errTrailingComma = errors.New("Trailing comma")
+
+// NewTokenizer returns a tokenizer reading from reader
+func NewTokenizer(reader *bufio.Reader) *Tokenizer {
+
+	return &Tokenizer{j: NewJSONParser(reader, "")}
+
+}
+
+// NewBytesTokenizer returns a tokenizer of data held in memory, see NewBytesJSONParser
+func NewBytesTokenizer(data []byte) *Tokenizer {
+
+	return &Tokenizer{j: NewBytesJSONParser(data, "")}
+
+}
+
+// Next returns the next token. At the end of the input it returns io.EOF,
+// and after an error it keeps returning that error.
+func (t *Tokenizer) Next() (Token, error) {
+
+	if t.err != nil {
+		return Token{}, t.err
+	}
+
+	tok, err := t.next()
+	if err != nil {
+		t.err = err
+		return Token{}, err
+	}
+	return tok, nil
+
+}
+
+func (t *Tokenizer) next() (Token, error) {
+
+	j := t.j
+
+	b, err := j.skipWS()
+
+	if err != nil {
+		if err == io.EOF && len(t.stack) == 0 {
+			return Token{}, io.EOF
+		}
+		return Token{}, j.defaultError()
+	}
+
+	n := len(t.stack)
+
+	if n == 0 || t.needValue {
+		return t.value(b)
+	}
+
+	top := t.stack[n-1]
+	end := top + 2 // ']' and '}' follow '[' and '{' by 2
+
+	if b == end {
+
+		if t.afterComma {
+			return Token{}, errTrailingComma
+		}
+
+		t.stack = t.stack[:n-1]
+		t.needComma = n > 1
+		tok := Token{Kind: TokenEndArray, Depth: n - 1, Offset: j.offset() - 1}
+		if top == '{' {
+			tok.Kind = TokenEndObject
+		}
+		return tok, nil
+
+	}
+
+	if t.needComma {
+
+		if b != ',' {
+			return Token{}, j.defaultError()
+		}
+
+		t.needComma = false
+		t.afterComma = true
+
+		b, err = j.skipWS()
+		if err != nil {
+			return Token{}, j.defaultError()
+		}
+
+		if b == end {
+			return Token{}, errTrailingComma
+		}
+
+	}
+
+	if top == '[' {
+		return t.value(b)
+	}
+
+	// member name of an object
+	if b != '"' {
+		return Token{}, j.defaultError()
+	}
+
+	offset := j.offset() - 1
+
+	err = j.string()
+	if err != nil {
+		return Token{}, err
+	}
+	key := j.scratch.string()
+
+	b, err = j.skipWS()
+	if err != nil || b != ':' {
+		return Token{}, j.defaultError()
+	}
+
+	t.afterComma = false
+	t.needValue = true
+	return Token{Kind: TokenKey, Value: key, Depth: n, Offset: offset}, nil
+
+}
+
+// value reads the value starting with b
+func (t *Tokenizer) value(b byte) (Token, error) {
+
+	j := t.j
+	n := len(t.stack)
+	tok := Token{Depth: n, Offset: j.offset() - 1}
+
+	t.afterComma = false
+	t.needValue = false
+
+	valType, err := j.getValueType(b)
+	if err != nil {
+		return Token{}, err
+	}
+
+	switch valType {
+	case Object, Array:
+
+		t.stack = append(t.stack, b)
+		t.needComma = false
+		tok.Kind = TokenStartArray
+		if valType == Object {
+			tok.Kind = TokenStartObject
+		}
+		return tok, nil
+
+	case String:
+
+		tok.Kind = TokenString
+		err = j.string()
+		tok.Value = j.scratch.string()
+
+	case Number:
+
+		tok.Kind = TokenNumber
+		j.root = n == 0
+		err = j.number(b)
+		j.root = false
+		if err == nil && !validNumber(j.scratch.bytes()) {
+			err = j.defaultError()
+		}
+		tok.Value = j.scratch.string()
+
+	case Boolean:
+
+		tok.Kind = TokenBool
+		j.root = n == 0
+		tok.Bool, err = j.boolean()
+		j.root = false
+
+	case Null:
+
+		tok.Kind = TokenNull
+		j.root = n == 0
+		err = j.null()
+		j.root = false
+
+	}
+
+	if err != nil {
+		return Token{}, err
+	}
+
+	t.needComma = n > 0
+	return tok, nil
+
+}
+
+// validNumber reports whether s is a number of the JSON grammar
+func validNumber(s []byte) bool {
+
+	digits := func(i int) int {
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		return i
+	}
+
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+
+	switch {
+	case i == len(s):
+		return false
+	case s[i] == '0':
+		i++
+	case s[i] >= '1' && s[i] <= '9':
+		i = digits(i)
+	default:
+		return false
+	}
+
+	if i < len(s) && s[i] == '.' {
+		k := digits(i + 1)
+		if k == i+1 {
+			return false
+		}
+		i = k
+	}
+
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		k := digits(i)
+		if k == i {
+			return false
+		}
+		i = k
+	}
+
+	return i == len(s)
+
+}